@@ -0,0 +1,174 @@
+// Package accesslog provides structured access logging middleware for a
+// warp.ServeMux. Each request is logged as one log/slog record describing
+// the request, the route it matched and the response:
+//
+//	logger := slog.New(slog.NewJSONHandler(os.Stderr, nil))
+//	mux.Use(accesslog.Middleware(logger, "token"))
+//
+// Routes may opt out of logging with the Skip tag:
+//
+//	mux.Get("/healthz", healthHandler).Tags(accesslog.Skip)
+package accesslog
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dghubble/warp"
+	"github.com/dghubble/warp/internal/response"
+)
+
+// Skip is the Route tag which opts requests dispatched to the route out of
+// access logging.
+const Skip = "accesslog.skip"
+
+// Redacted replaces the values of redacted query and path params.
+const Redacted = "REDACTED"
+
+// Middleware returns warp.Middleware which logs each request dispatched by a
+// mux with the logger, as a record with the attributes method, host, path,
+// query, pattern, route (the Route's "name" metadata), params, status,
+// bytes, duration and client_ip. Requests which fail with a 5xx status are
+// logged at LevelError, others at LevelInfo.
+//
+// The values of query params and path params with the redacted names (e.g.
+// "token" redacts both "?token=" and ":token") are replaced by Redacted, as
+// are the path segments the redacted path params were captured from. The
+// query is logged as the client sent it, without the ':' prefixed path
+// params ServeMux adds, which are logged in the params group.
+func Middleware(logger *slog.Logger, redacted ...string) warp.Middleware {
+	redact := make(map[string]bool, len(redacted))
+	for _, name := range redacted {
+		redact[strings.TrimPrefix(name, ":")] = true
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := warp.RequestRoute(r)
+			if route != nil && route.HasTag(Skip) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			params := warp.RequestParams(r)
+			query := r.URL.RawQuery
+			if len(params) > 0 {
+				query = strings.TrimPrefix(query, params.Encode()+"&")
+			}
+			var name string
+			if route != nil {
+				name, _ = route.Value("name").(string)
+			}
+
+			start := time.Now()
+			recorder := response.NewRecorder(w)
+			next.ServeHTTP(recorder, r)
+			status := recorder.Status()
+
+			level := slog.LevelInfo
+			if status >= 500 {
+				level = slog.LevelError
+			}
+			logger.LogAttrs(r.Context(), level, "request",
+				slog.String("method", r.Method),
+				slog.String("host", r.Host),
+				slog.String("path", redactPath(r.URL, params, redact)),
+				slog.String("query", redactQuery(query, redact)),
+				slog.String("pattern", warp.RequestPattern(r)),
+				slog.String("route", name),
+				slog.Attr{Key: "params", Value: slog.GroupValue(paramAttrs(params, redact)...)},
+				slog.Int("status", status),
+				slog.Int64("bytes", recorder.Size),
+				slog.Duration("duration", time.Since(start)),
+				slog.String("client_ip", clientIP(r)),
+			)
+		})
+	}
+}
+
+// redactQuery returns the raw query with the values of the redacted params
+// replaced, keeping the order and encoding of the other params.
+func redactQuery(query string, redact map[string]bool) string {
+	if query == "" || len(redact) == 0 {
+		return query
+	}
+	pairs := strings.Split(query, "&")
+	for i, pair := range pairs {
+		rawKey, _, _ := strings.Cut(pair, "=")
+		key, err := url.QueryUnescape(rawKey)
+		if err != nil {
+			key = rawKey
+		}
+		if redact[key] {
+			pairs[i] = rawKey + "=" + Redacted
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// redactPath returns the path of the URL with each occurrence of a value a
+// redacted param captured replaced by Redacted, including values captured
+// from part of a segment, as by "/reset/:token.:ext".
+func redactPath(u *url.URL, params url.Values, redact map[string]bool) string {
+	var secrets []string
+	for key, values := range params {
+		if redact[strings.TrimPrefix(key, ":")] {
+			for _, value := range values {
+				if value != "" {
+					secrets = append(secrets, value)
+				}
+			}
+		}
+	}
+	if len(secrets) == 0 {
+		return u.Path
+	}
+	// replace longer secrets first, so secrets containing others are
+	// replaced whole
+	sort.Slice(secrets, func(i, j int) bool {
+		return len(secrets[i]) > len(secrets[j])
+	})
+	// split the escaped path, so captures containing encoded slashes are
+	// one segment
+	segments := strings.Split(u.EscapedPath(), "/")
+	for i, segment := range segments {
+		if unescaped, err := url.PathUnescape(segment); err == nil {
+			segment = unescaped
+		}
+		for _, secret := range secrets {
+			segment = strings.ReplaceAll(segment, secret, Redacted)
+		}
+		segments[i] = segment
+	}
+	return strings.Join(segments, "/")
+}
+
+// paramAttrs returns the path params as attributes named without their ':'
+// prefix, sorted by name, with the redacted params' values replaced.
+func paramAttrs(params url.Values, redact map[string]bool) []slog.Attr {
+	attrs := make([]slog.Attr, 0, len(params))
+	for key, values := range params {
+		name := strings.TrimPrefix(key, ":")
+		value := strings.Join(values, "/")
+		if redact[name] {
+			value = Redacted
+		}
+		attrs = append(attrs, slog.String(name, value))
+	}
+	sort.Slice(attrs, func(i, j int) bool {
+		return attrs[i].Key < attrs[j].Key
+	})
+	return attrs
+}
+
+// clientIP returns the IP address of the client connection.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,118 @@
+package accesslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dghubble/warp"
+)
+
+func TestMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	mux := warp.NewServeMux()
+	mux.Use(Middleware(logger, "token", "secret"))
+	mux.Get("/users/:id/keys/:secret", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("key"))
+	})).Meta("name", "user-key")
+	mux.Get("/reset/:token.:ext", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	mux.Get("/files/*secret", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	mux.Get("/fail", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "failed", http.StatusInternalServerError)
+	}))
+	mux.Get("/healthz", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).Tags(Skip)
+
+	cases := []struct {
+		url    string
+		record map[string]interface{} // expected record fields, nil if skipped
+		secret string                 // value which must not be logged
+	}{
+		{"/users/7/keys/abc?token=t0k&page=2&token=again", map[string]interface{}{
+			"level":     "INFO",
+			"msg":       "request",
+			"method":    "GET",
+			"host":      "example.com",
+			"path":      "/users/7/keys/REDACTED",
+			"query":     "token=REDACTED&page=2&token=REDACTED",
+			"pattern":   "/users/:id/keys/:secret",
+			"route":     "user-key",
+			"params":    map[string]interface{}{"id": "7", "secret": "REDACTED"},
+			"status":    200.0,
+			"bytes":     3.0,
+			"client_ip": "192.0.2.1",
+		}, "abc"},
+		// captures sharing a segment
+		{"/reset/s3cret.json", map[string]interface{}{
+			"path":   "/reset/REDACTED.json",
+			"params": map[string]interface{}{"token": "REDACTED", "ext": "json"},
+		}, "s3cret"},
+		{"/files/private/key.pem", map[string]interface{}{
+			"path":   "/files/REDACTED/REDACTED",
+			"params": map[string]interface{}{"secret": "REDACTED"},
+		}, "private"},
+		{"/fail", map[string]interface{}{
+			"level":   "ERROR",
+			"path":    "/fail",
+			"query":   "",
+			"pattern": "/fail",
+			"route":   "",
+			"status":  500.0,
+		}, ""},
+		{"/missing?q=1", map[string]interface{}{
+			"query":   "q=1",
+			"pattern": "",
+			"status":  404.0,
+		}, ""},
+		{"/healthz", nil, ""},
+	}
+	for _, c := range cases {
+		buf.Reset()
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", c.url, nil))
+		if c.record == nil {
+			if buf.Len() != 0 {
+				t.Errorf("GET %s logged %s, want skipped", c.url, buf.String())
+			}
+			continue
+		}
+		var record map[string]interface{}
+		if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+			t.Fatalf("GET %s logged %q: %v", c.url, buf.String(), err)
+		}
+		for key, want := range c.record {
+			if got := record[key]; !reflect.DeepEqual(got, want) {
+				t.Errorf("GET %s record %s = %v, want %v", c.url, key, got, want)
+			}
+		}
+		if c.secret != "" && strings.Contains(buf.String(), c.secret) {
+			t.Errorf("GET %s logged redacted value %q: %s", c.url, c.secret, buf.String())
+		}
+		if _, ok := record["duration"]; !ok {
+			t.Errorf("GET %s record has no duration", c.url)
+		}
+	}
+}
+
+func TestRedactQuery(t *testing.T) {
+	redact := map[string]bool{"token": true, "api key": true}
+	cases := []struct {
+		query    string
+		expected string
+	}{
+		{"", ""},
+		{"a=1&b=2", "a=1&b=2"},
+		{"token=x&a=1", "token=REDACTED&a=1"},
+		{"token&a=%2F", "token=REDACTED&a=%2F"},
+		{"api+key=x&api%20key=y", "api+key=REDACTED&api%20key=REDACTED"},
+	}
+	for _, c := range cases {
+		if redacted := redactQuery(c.query, redact); redacted != c.expected {
+			t.Errorf("redactQuery(%q) = %q, want %q", c.query, redacted, c.expected)
+		}
+	}
+}
@@ -0,0 +1,429 @@
+// Package config loads the routes of a warp.ServeMux from declarative JSON
+// or YAML files, so routes can change without rebuilding the program:
+//
+//	err := config.Load(mux, "routes.yaml", &config.Registry{
+//		Handlers: map[string]http.Handler{"reports": reportsHandler},
+//	})
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dghubble/warp"
+)
+
+// Config is a declarative route table, decoded from a JSON or YAML file, so
+// routes can change without rebuilding the program. Routes refer to
+// handlers and rules by the names they have in a Registry:
+//
+//	routes:
+//	  - pattern: /reports/:id
+//	    methods: [GET]
+//	    handler: reports
+//	  - pattern: /old/reports/:id
+//	    redirect: /reports/:id
+//	  - host: beta.example.com
+//	    pattern: /search
+//	    handler: search
+//	    rules: [beta]
+//
+// YAML configs may use block mappings and sequences, single line flow
+// sequences, quoted scalars and comments, but not anchors, tags, flow
+// mappings or multi-line scalars.
+type Config struct {
+	Routes []Route `json:"routes"`
+
+	file string // name of the config file, for errors
+}
+
+// Route describes a warp.Route of a Config. Each route has either the name
+// of a Registry Handler or a Redirect target.
+type Route struct {
+	Host        string                 `json:"host"`        // host prefixed to the Pattern
+	Pattern     string                 `json:"pattern"`     // path pattern, starting with /
+	Methods     []string               `json:"methods"`     // allowed methods, any if empty
+	Handler     string                 `json:"handler"`     // name of a Registry handler
+	Redirect    string                 `json:"redirect"`    // redirect target, which may use :params
+	Code        int                    `json:"code"`        // redirect status code, 301 if zero
+	Rules       []string               `json:"rules"`       // names of Registry rules
+	Accept      []string               `json:"accept"`      // see warp.Route.Accept
+	ContentType []string               `json:"contentType"` // see warp.Route.ContentType
+	Formats     []string               `json:"formats"`     // see warp.Route.Formats
+	Priority    int                    `json:"priority"`    // see warp.Route.Priority
+	Tags        []string               `json:"tags"`        // see warp.Route.Tags
+	Meta        map[string]interface{} `json:"meta"`        // see warp.Route.Meta
+
+	line int // line of the route in the config file, or 0
+}
+
+// Registry names the handlers and rules a Config may refer to.
+type Registry struct {
+	Handlers map[string]http.Handler
+	Rules    map[string]warp.Rule
+}
+
+// Error is an error in a Config, with the file and line of the route
+// which caused it, if known.
+type Error struct {
+	File string
+	Line int
+	Err  error
+}
+
+func (e *Error) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("%s:%d: %v", e.File, e.Line, e.Err)
+	}
+	return fmt.Sprintf("%s: %v", e.File, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Parse parses the data of the named config file. Files with a .json
+// extension are decoded as JSON, and files with a .yaml or .yml extension as
+// YAML. Errors are *Errors.
+func Parse(name string, data []byte) (*Config, error) {
+	var config *Config
+	var err error
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json":
+		config, err = parseJSONConfig(name, data)
+	case ".yaml", ".yml":
+		config, err = parseYAMLConfig(name, data)
+	default:
+		return nil, &Error{File: name, Err: errors.New("unknown config format, want .json, .yaml or .yml")}
+	}
+	if err != nil {
+		return nil, err
+	}
+	config.file = name
+	return config, nil
+}
+
+// Register registers the routes of the Config on the mux, resolving handler
+// and rule names with the registry. Returns an *Error for the first
+// invalid route, in which case earlier routes are already registered.
+func (config *Config) Register(mux *warp.ServeMux, registry *Registry) error {
+	if registry == nil {
+		registry = &Registry{}
+	}
+	for i, rc := range config.Routes {
+		if err := rc.register(mux, registry); err != nil {
+			if rc.line == 0 {
+				err = fmt.Errorf("route %d: %v", i, err)
+			}
+			return &Error{File: config.file, Line: rc.line, Err: err}
+		}
+	}
+	return nil
+}
+
+// register validates the route and registers it on the mux. Panics from registering invalid patterns are returned as
+// errors.
+func (rc *Route) register(mux *warp.ServeMux, registry *Registry) (err error) {
+	pattern := rc.Host + rc.Pattern
+	if rc.Pattern == "" {
+		return errors.New("route has no pattern")
+	}
+	// the pattern would otherwise be split into a host and path
+	if !strings.HasPrefix(rc.Pattern, "/") {
+		return fmt.Errorf("route pattern %s does not start with /", rc.Pattern)
+	}
+	var handler http.Handler
+	switch {
+	case rc.Handler != "" && rc.Redirect != "":
+		return fmt.Errorf("route %s has both a handler and a redirect", pattern)
+	case rc.Handler != "":
+		if handler = registry.Handlers[rc.Handler]; handler == nil {
+			return fmt.Errorf("route %s has unknown handler %q", pattern, rc.Handler)
+		}
+		if rc.Code != 0 {
+			return fmt.Errorf("route %s has a code but no redirect", pattern)
+		}
+	case rc.Redirect != "":
+		code := rc.Code
+		if code == 0 {
+			code = http.StatusMovedPermanently
+		}
+		if code < 300 || code > 399 {
+			return fmt.Errorf("route %s has invalid redirect code %d", pattern, code)
+		}
+		handler = &redirectHandler{target: rc.Redirect, code: code}
+	default:
+		return fmt.Errorf("route %s has no handler or redirect", pattern)
+	}
+	var rules []warp.Rule
+	if len(rc.Methods) > 0 {
+		rules = append(rules, warp.NewMethodRule(append([]string(nil), rc.Methods...)...))
+	}
+	for _, name := range rc.Rules {
+		rule := registry.Rules[name]
+		if rule == nil {
+			return fmt.Errorf("route %s has unknown rule %q", pattern, name)
+		}
+		rules = append(rules, rule)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	route := mux.Register(pattern, handler, rules...)
+	if len(rc.Accept) > 0 {
+		route.Accept(rc.Accept...)
+	}
+	if len(rc.ContentType) > 0 {
+		route.ContentType(rc.ContentType...)
+	}
+	if len(rc.Formats) > 0 {
+		route.Formats(rc.Formats...)
+	}
+	for key, value := range rc.Meta {
+		route.Meta(key, value)
+	}
+	route.Priority(rc.Priority).Tags(rc.Tags...)
+	return nil
+}
+
+// Load reads the config file and atomically replaces the routes the mux
+// loaded from the file before, if any, with the config routes (see
+// warp.ServeMux.ReplaceSource). Routes
+// registered in code or loaded from other files are kept. If the config is
+// invalid, returns an *Error pointing at the offending line and keeps
+// the current routes.
+func Load(mux *warp.ServeMux, filename string, registry *Registry) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	config, err := Parse(filename, data)
+	if err != nil {
+		return err
+	}
+	loaded := warp.NewServeMux()
+	if err := config.Register(loaded, registry); err != nil {
+		return err
+	}
+	mux.ReplaceSource(filename, loaded)
+	return nil
+}
+
+// Watch loads the config file into the mux, as Load does, then
+// polls the file every interval and reloads it once its modification time
+// or size has changed and stayed the same for an interval, so partially
+// written files are not loaded. Errors reloading the file are passed to
+// onError, if non-nil, and the routes loaded before are kept. Returns a
+// function which stops watching, or the error of the initial load. The
+// interval must be positive.
+func Watch(mux *warp.ServeMux, filename string, registry *Registry, interval time.Duration, onError func(error)) (stop func(), err error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("config: non-positive watch interval %v", interval)
+	}
+	loaded, err := os.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+	if err := Load(mux, filename, registry); err != nil {
+		return nil, err
+	}
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		var pending os.FileInfo // changed file info, waiting to settle
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			info, err := os.Stat(filename)
+			switch {
+			case err != nil:
+			case sameFileInfo(info, loaded):
+				pending = nil
+				continue
+			case pending == nil || !sameFileInfo(info, pending):
+				pending = info
+				continue
+			default:
+				loaded, pending = info, nil
+				err = Load(mux, filename, registry)
+			}
+			if err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }, nil
+}
+
+// sameFileInfo returns true if the file infos have the same modification
+// time and size.
+func sameFileInfo(info, other os.FileInfo) bool {
+	return info.ModTime().Equal(other.ModTime()) && info.Size() == other.Size()
+}
+
+// redirectHandler redirects requests to its target, with the :params of the
+// target replaced by the values captured from the request path.
+type redirectHandler struct {
+	target string
+	code   int
+}
+
+func (h *redirectHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	http.Redirect(w, r, expandTarget(h.target, warp.RequestParams(r)), h.code)
+}
+
+// String describes the redirect, as listed by warp.ServeMux.Dump.
+func (h *redirectHandler) String() string {
+	return "redirect " + h.target
+}
+
+// expandTarget replaces each :name of the redirect target with the escaped
+// value of the :name param. Params with several values, as captured by
+// splats, are joined by slashes. Names without values are kept as is.
+func expandTarget(target string, params url.Values) string {
+	var expanded []rune
+	rTarget := []rune(target)
+	for i := 0; i < len(rTarget); {
+		if rTarget[i] != ':' {
+			expanded = append(expanded, rTarget[i])
+			i++
+			continue
+		}
+		next := i + 1
+		for next < len(rTarget) && isNameRune(rTarget[next]) {
+			next++
+		}
+		name := string(rTarget[i+1 : next])
+		values, ok := params[":"+name]
+		if name == "" || !ok {
+			expanded = append(expanded, rTarget[i:next]...)
+			i = next
+			continue
+		}
+		escaped := make([]string, len(values))
+		for j, value := range values {
+			escaped[j] = url.PathEscape(value)
+		}
+		expanded = append(expanded, []rune(strings.Join(escaped, "/"))...)
+		i = next
+	}
+	return string(expanded)
+}
+
+// isNameRune returns true if the rune may be part of a :param name, as in
+// warp patterns.
+func isNameRune(r rune) bool {
+	return !strings.ContainsRune("!#$&'()*+,/:;=?@[]%-.<>\\^`{|}~", r)
+}
+
+// parseJSONConfig decodes a JSON config, recording the line of each route.
+func parseJSONConfig(name string, data []byte) (*Config, error) {
+	config := new(Config)
+	dec := json.NewDecoder(bytes.NewReader(data))
+	errorAt := func(err error) error {
+		offset := dec.InputOffset()
+		if syntaxErr, ok := err.(*json.SyntaxError); ok {
+			offset = syntaxErr.Offset
+		}
+		return &Error{File: name, Line: lineAt(data, offset), Err: err}
+	}
+	if err := expectDelim(dec, '{'); err != nil {
+		return nil, errorAt(err)
+	}
+	for dec.More() {
+		start := skipJSONSpace(data, dec.InputOffset())
+		key, err := dec.Token()
+		if err != nil {
+			return nil, errorAt(err)
+		}
+		if key != "routes" {
+			return nil, &Error{File: name, Line: lineAt(data, start), Err: fmt.Errorf("unknown field %q", key)}
+		}
+		if err := expectDelim(dec, '['); err != nil {
+			return nil, errorAt(err)
+		}
+		for dec.More() {
+			start := skipJSONSpace(data, dec.InputOffset())
+			var raw json.RawMessage
+			if err := dec.Decode(&raw); err != nil {
+				return nil, errorAt(err)
+			}
+			rc, err := decodeRoute(raw)
+			rc.line = lineAt(data, start)
+			if err != nil {
+				line := rc.line
+				if typeErr, ok := err.(*json.UnmarshalTypeError); ok {
+					line += bytes.Count(raw[:typeErr.Offset], []byte("\n"))
+				}
+				return nil, &Error{File: name, Line: line, Err: err}
+			}
+			config.Routes = append(config.Routes, rc)
+		}
+		if err := expectDelim(dec, ']'); err != nil {
+			return nil, errorAt(err)
+		}
+	}
+	if err := expectDelim(dec, '}'); err != nil {
+		return nil, errorAt(err)
+	}
+	if _, err := dec.Token(); err == nil {
+		return nil, errorAt(errors.New("unexpected data after config"))
+	}
+	return config, nil
+}
+
+// decodeRoute decodes the JSON of a route, rejecting unknown fields.
+func decodeRoute(raw []byte) (Route, error) {
+	var rc Route
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&rc)
+	return rc, err
+}
+
+// expectDelim reads the next JSON token, which should be the delimiter.
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return fmt.Errorf("expected %v, found %v", delim, token)
+	}
+	return nil
+}
+
+// skipJSONSpace returns the offset of the first byte at or after the offset
+// which is not whitespace or a separator.
+func skipJSONSpace(data []byte, offset int64) int64 {
+	for offset < int64(len(data)) && strings.IndexByte(" \t\r\n,:", data[offset]) >= 0 {
+		offset++
+	}
+	return offset
+}
+
+// lineAt returns the 1-based line number of the byte offset in data.
+func lineAt(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
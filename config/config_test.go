@@ -0,0 +1,317 @@
+package config
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dghubble/warp"
+)
+
+const testJSONConfig = `{
+  "routes": [
+    {"pattern": "/reports/:id", "methods": ["GET"], "handler": "reports", "tags": ["reports"]},
+    {
+      "pattern": "/old/reports/*path",
+      "redirect": "/reports/:path",
+      "code": 308
+    },
+    {"host": "beta.example.com", "pattern": "/search", "handler": "search", "rules": ["beta"]}
+  ]
+}`
+
+const testYAMLConfig = `# report routes
+routes:
+  - pattern: /reports/:id
+    methods: [GET]
+    handler: reports
+    tags:
+    - reports
+  - pattern: "/old/reports/*path"   # moved
+    redirect: /reports/:path
+    code: 308
+  -
+    host: beta.example.com
+    pattern: /search
+    handler: search
+    rules: ['beta']
+`
+
+// stringHandler sets the Result header to its string.
+type stringHandler string
+
+func (s stringHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Result", string(s))
+}
+
+// headerRule allows requests with its header.
+type headerRule string
+
+func (rule headerRule) Allows(request *http.Request) bool {
+	return request.Header.Get(string(rule)) != ""
+}
+
+func newRequest(method, urlStr string) *http.Request {
+	request, err := http.NewRequest(method, urlStr, nil)
+	if err != nil {
+		panic(err)
+	}
+	return request
+}
+
+func testRegistry() *Registry {
+	return &Registry{
+		Handlers: map[string]http.Handler{
+			"reports": stringHandler("reports"),
+			"search":  stringHandler("search"),
+		},
+		Rules: map[string]warp.Rule{
+			"beta": headerRule("X-Beta"),
+		},
+	}
+}
+
+func TestParse(t *testing.T) {
+	expected := []Route{
+		{Pattern: "/reports/:id", Methods: []string{"GET"}, Handler: "reports", Tags: []string{"reports"}},
+		{Pattern: "/old/reports/*path", Redirect: "/reports/:path", Code: 308},
+		{Host: "beta.example.com", Pattern: "/search", Handler: "search", Rules: []string{"beta"}},
+	}
+	cases := []struct {
+		name  string
+		data  string
+		lines []int
+	}{
+		{"routes.json", testJSONConfig, []int{3, 4, 9}},
+		{"routes.yaml", testYAMLConfig, []int{3, 8, 11}},
+	}
+	for _, c := range cases {
+		config, err := Parse(c.name, []byte(c.data))
+		if err != nil {
+			t.Fatalf("Parse(%s) error %v", c.name, err)
+		}
+		if len(config.Routes) != len(expected) {
+			t.Fatalf("Parse(%s) = %d routes, want %d", c.name, len(config.Routes), len(expected))
+		}
+		for i, rc := range config.Routes {
+			if rc.line != c.lines[i] {
+				t.Errorf("Parse(%s) route %d line %d, want %d", c.name, i, rc.line, c.lines[i])
+			}
+			rc.line = 0
+			if !reflect.DeepEqual(rc, expected[i]) {
+				t.Errorf("Parse(%s) route %d = %+v, want %+v", c.name, i, rc, expected[i])
+			}
+		}
+	}
+}
+
+var configErrorTests = []struct {
+	name string
+	data string
+	err  string // expected error
+}{
+	{"bad.toml", "", "bad.toml: unknown config format, want .json, .yaml or .yml"},
+	{"bad.json", "{\n  \"routes\": [\n    {\"pattern\": \"/a\",}\n  ]\n}", "bad.json:3: invalid character '}' looking for beginning of object key string"},
+	{"bad.json", "{\n  \"routes\": [],\n  \"hosts\": []\n}", `bad.json:3: unknown field "hosts"`},
+	{"bad.json", "{\"routes\": [\n  {\"pattern\": \"/a\",\n   \"methods\": \"GET\"}\n]}", "bad.json:3: json: cannot unmarshal string into Go struct field Route.methods of type []string"},
+	{"bad.json", "{\"routes\": [\n  {\"pattern\": \"/a\", \"handlr\": \"a\"}\n]}", `bad.json:2: json: unknown field "handlr"`},
+	{"bad.yaml", "routes:\n  - pattern: /a\n    methods: GET\n", "bad.yaml:3: json: cannot unmarshal string into Go struct field Route.methods of type []string"},
+	{"bad.yaml", "routes:\n  - pattern: /a\n      handler: a\n", "bad.yaml:3: unexpected indentation"},
+	{"bad.yaml", "routes:\n  - pattern: /a\n    pattern: /b\n", `bad.yaml:3: duplicate key "pattern"`},
+	{"bad.yaml", "routes:\n  - host: *.example.com\n", "bad.yaml:2: unsupported YAML syntax *.example.com"},
+	{"bad.yaml", "routes:\n\t- pattern: /a\n", "bad.yaml:2: tabs are not allowed in indentation"},
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, ct := range configErrorTests {
+		_, err := Parse(ct.name, []byte(ct.data))
+		if err == nil || err.Error() != ct.err {
+			t.Errorf("Parse(%s, %q) error %v, want %s", ct.name, ct.data, err, ct.err)
+		}
+		var configErr *Error
+		if !errors.As(err, &configErr) {
+			t.Errorf("Parse(%s, %q) error %T, want *Error", ct.name, ct.data, err)
+		}
+	}
+}
+
+var registerErrorTests = []struct {
+	data string
+	err  string // expected error
+}{
+	{"routes:\n  - handler: reports\n", "routes.yaml:2: route has no pattern"},
+	{"routes:\n  - pattern: /a\n", "routes.yaml:2: route /a has no handler or redirect"},
+	{"routes:\n  - pattern: /a\n    handler: reports\n  - pattern: /b\n    handler: missing\n", `routes.yaml:4: route /b has unknown handler "missing"`},
+	{"routes:\n  - pattern: /a\n    handler: reports\n    rules: [alpha]\n", `routes.yaml:2: route /a has unknown rule "alpha"`},
+	{"routes:\n  - pattern: /a\n    redirect: /b\n    code: 200\n", "routes.yaml:2: route /a has invalid redirect code 200"},
+	{"routes:\n  - pattern: /a(/b\n    handler: reports\n", "routes.yaml:2: warp: invalid pattern /a(/b"},
+	{"routes:\n  - pattern: /a\n    handler: reports\n  - pattern: reports/:id\n    handler: reports\n", "routes.yaml:4: route pattern reports/:id does not start with /"},
+	{"routes:\n  - host: example.com\n    pattern: example.com/a\n    handler: reports\n", "routes.yaml:2: route pattern example.com/a does not start with /"},
+}
+
+func TestRegisterErrors(t *testing.T) {
+	for _, rt := range registerErrorTests {
+		config, err := Parse("routes.yaml", []byte(rt.data))
+		if err != nil {
+			t.Fatalf("Parse(%q) error %v", rt.data, err)
+		}
+		err = config.Register(warp.NewServeMux(), testRegistry())
+		if err == nil || err.Error() != rt.err {
+			t.Errorf("Register(%q) error %v, want %s", rt.data, err, rt.err)
+		}
+	}
+}
+
+var configServeTests = []struct {
+	url      string
+	header   string // header to set
+	code     int
+	result   string // expected Result header
+	location string // expected Location header
+}{
+	{"/reports/42", "", 200, "reports", ""},
+	{"/old/reports/a/b c", "", 308, "", "/reports/a/b%20c"},
+	// ':' prefixed query params cannot inject redirect path segments
+	{"/old/reports/a?%3Apath=evil.example.com", "", 308, "", "/reports/a"},
+	{"http://beta.example.com/search", "X-Beta", 200, "search", ""},
+	{"http://beta.example.com/search", "", 404, "", ""},
+}
+
+func TestLoad(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "routes.json")
+	if err := os.WriteFile(filename, []byte(testJSONConfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+	mux := warp.NewServeMux()
+	// routes registered in code, e.g. OpenAPIHandler or a metrics collector
+	mux.Get("/kept", stringHandler("kept"))
+	if err := Load(mux, filename, testRegistry()); err != nil {
+		t.Fatalf("Load() error %v", err)
+	}
+
+	for _, ct := range configServeTests {
+		r := newRequest("GET", ct.url)
+		if ct.header != "" {
+			r.Header.Set(ct.header, "1")
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != ct.code || w.Header().Get("Result") != ct.result || w.Header().Get("Location") != ct.location {
+			t.Errorf("GET %s -> %d %q %q, want %d %q %q", ct.url, w.Code, w.Header().Get("Result"), w.Header().Get("Location"), ct.code, ct.result, ct.location)
+		}
+	}
+
+	if dump := mux.String(); !strings.Contains(dump, "redirect /reports/:path") {
+		t.Errorf("String() =\n%s\nwant redirect /reports/:path handler", dump)
+	}
+
+	// reloads replace only the routes loaded from the file
+	if err := os.WriteFile(filename, []byte(`{"routes": [{"pattern": "/v2", "handler": "reports"}]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := Load(mux, filename, testRegistry()); err != nil {
+		t.Fatalf("Load() reload error %v", err)
+	}
+	for path, want := range map[string]string{"/kept": "/kept", "/v2": "/v2", "/reports/42": ""} {
+		if _, pattern := mux.Handler(newRequest("GET", path)); pattern != want {
+			t.Errorf("GET %s after reload -> pattern %q, want %q", path, pattern, want)
+		}
+	}
+	if _, pattern := mux.Handler(newRequest("GET", "http://beta.example.com/search")); pattern != "" {
+		t.Errorf("GET beta.example.com/search after reload -> pattern %q, want removed", pattern)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "routes.yaml")
+	// replace the file atomically, with distinct modification times
+	write := func(data string, modTime time.Time) {
+		temp := filename + ".tmp"
+		if err := os.WriteFile(temp, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(temp, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(temp, filename); err != nil {
+			t.Fatal(err)
+		}
+	}
+	start := time.Now()
+	write("routes:\n  - pattern: /v1\n    handler: reports\n", start)
+
+	mux := warp.NewServeMux()
+	errs := make(chan error, 10)
+	stop, err := Watch(mux, filename, testRegistry(), 5*time.Millisecond, func(err error) {
+		errs <- err
+	})
+	if err != nil {
+		t.Fatalf("Watch() error %v", err)
+	}
+	defer stop()
+	waitForPattern := func(path, want string) {
+		deadline := time.Now().Add(2 * time.Second)
+		for time.Now().Before(deadline) {
+			if _, pattern := mux.Handler(newRequest("GET", path)); pattern == want {
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		t.Errorf("GET %s did not match pattern %q", path, want)
+	}
+	waitForPattern("/v1", "/v1")
+
+	write("routes:\n  - pattern: /v2\n    handler: reports\n", start.Add(time.Second))
+	waitForPattern("/v2", "/v2")
+	waitForPattern("/v1", "")
+
+	// invalid configs are reported and the loaded routes kept
+	write("routes:\n  - pattern: /v3\n    handler: missing\n", start.Add(2*time.Second))
+	select {
+	case err := <-errs:
+		if !strings.HasPrefix(err.Error(), filename+":2: ") {
+			t.Errorf("Watch() reported error %v, want line 2", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("Watch() did not report the invalid config")
+	}
+	waitForPattern("/v2", "/v2")
+}
+
+func TestWatchInterval(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "routes.json")
+	if err := os.WriteFile(filename, []byte(testJSONConfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+	mux := warp.NewServeMux()
+	for _, interval := range []time.Duration{0, -time.Second} {
+		if stop, err := Watch(mux, filename, testRegistry(), interval, nil); err == nil {
+			stop()
+			t.Errorf("Watch() interval %v error nil, want error", interval)
+		}
+	}
+	if _, pattern := mux.Handler(newRequest("GET", "/reports/42")); pattern != "" {
+		t.Errorf("Watch() with invalid interval loaded routes")
+	}
+}
+
+func TestExpandTarget(t *testing.T) {
+	params := map[string][]string{":id": {"42"}, ":path": {"a", "b"}}
+	cases := map[string]string{
+		"/reports/:id":                 "/reports/42",
+		"/files/:path/raw":             "/files/a/b/raw",
+		"https://example.com:8080/:id": "https://example.com:8080/42",
+		"/reports/:missing?source=:id": "/reports/:missing?source=42",
+	}
+	for target, expected := range cases {
+		if expanded := expandTarget(target, params); expanded != expected {
+			t.Errorf("expandTarget(%q) = %q, want %q", target, expanded, expected)
+		}
+	}
+}
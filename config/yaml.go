@@ -0,0 +1,396 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// yamlNode is a node of a YAML document, with the line it starts on.
+type yamlNode struct {
+	line  int
+	value interface{} // map[string]*yamlNode, []*yamlNode, or a scalar
+}
+
+// yamlLine is a non-empty line of a YAML document, without comments.
+type yamlLine struct {
+	num    int    // 1-based line number
+	indent int    // num leading spaces
+	text   string // line text after the indentation
+}
+
+// yamlParser parses the block structure of the subset of YAML which Config
+// files may use.
+type yamlParser struct {
+	lines []yamlLine
+	i     int // index of the next line to parse
+}
+
+// yamlError is a YAML syntax error on a line.
+type yamlError struct {
+	line int
+	err  error
+}
+
+func (e *yamlError) Error() string {
+	return e.err.Error()
+}
+
+// parseYAMLConfig decodes a YAML config, recording the line of each route.
+func parseYAMLConfig(name string, data []byte) (*Config, error) {
+	root, err := parseYAML(string(data))
+	if err != nil {
+		yamlErr := err.(*yamlError)
+		return nil, &Error{File: name, Line: yamlErr.line, Err: yamlErr.err}
+	}
+	config := new(Config)
+	if root.value == nil {
+		return config, nil
+	}
+	fields, ok := root.value.(map[string]*yamlNode)
+	if !ok {
+		return nil, &Error{File: name, Line: root.line, Err: errors.New("config is not a mapping")}
+	}
+	for key, node := range fields {
+		if key != "routes" {
+			return nil, &Error{File: name, Line: node.line, Err: fmt.Errorf("unknown field %q", key)}
+		}
+	}
+	routes := fields["routes"]
+	if routes == nil || routes.value == nil {
+		return config, nil
+	}
+	items, ok := routes.value.([]*yamlNode)
+	if !ok {
+		return nil, &Error{File: name, Line: routes.line, Err: errors.New("routes is not a sequence")}
+	}
+	for _, item := range items {
+		raw, err := json.Marshal(item.plain())
+		if err != nil {
+			return nil, &Error{File: name, Line: item.line, Err: err}
+		}
+		rc, err := decodeRoute(raw)
+		rc.line = item.line
+		if err != nil {
+			line := item.line
+			// point at the field whose value has the wrong type
+			if typeErr, ok := err.(*json.UnmarshalTypeError); ok {
+				if route, ok := item.value.(map[string]*yamlNode); ok && route[typeErr.Field] != nil {
+					line = route[typeErr.Field].line
+				}
+			}
+			return nil, &Error{File: name, Line: line, Err: err}
+		}
+		config.Routes = append(config.Routes, rc)
+	}
+	return config, nil
+}
+
+// parseYAML parses a YAML document into a tree of nodes. Errors are
+// *yamlErrors.
+func parseYAML(document string) (*yamlNode, error) {
+	lines, err := yamlLines(document)
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 {
+		return &yamlNode{line: 1}, nil
+	}
+	p := &yamlParser{lines: lines}
+	root, err := p.parse(lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.i < len(lines) {
+		return nil, p.errorf("unexpected indentation")
+	}
+	return root, nil
+}
+
+// yamlLines splits the document into its non-empty lines, stripped of
+// comments and document markers.
+func yamlLines(document string) ([]yamlLine, error) {
+	var lines []yamlLine
+	for i, text := range strings.Split(document, "\n") {
+		text = strings.TrimRight(stripYAMLComment(text), " \t\r")
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || trimmed == "---" || trimmed == "..." {
+			continue
+		}
+		if trimmed[0] == '\t' {
+			return nil, &yamlError{i + 1, errors.New("tabs are not allowed in indentation")}
+		}
+		lines = append(lines, yamlLine{num: i + 1, indent: len(text) - len(trimmed), text: trimmed})
+	}
+	return lines, nil
+}
+
+// stripYAMLComment removes a comment, which starts at a '#' at the start of
+// the line or after a space, outside quotes.
+func stripYAMLComment(text string) string {
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || text[i-1] == ' ' || text[i-1] == '\t'):
+			return text[:i]
+		}
+	}
+	return text
+}
+
+// errorf returns a *yamlError for the current line.
+func (p *yamlParser) errorf(format string, args ...interface{}) error {
+	line := p.lines[len(p.lines)-1].num
+	if p.i < len(p.lines) {
+		line = p.lines[p.i].num
+	}
+	return &yamlError{line, fmt.Errorf(format, args...)}
+}
+
+// parse parses the block node starting at the current line, which has the
+// given indent.
+func (p *yamlParser) parse(indent int) (*yamlNode, error) {
+	line := p.lines[p.i]
+	if isYAMLItem(line.text) {
+		return p.parseSequence(indent)
+	}
+	if _, _, ok := splitYAMLKey(line.text); ok {
+		return p.parseMapping(indent)
+	}
+	value, err := yamlScalar(line.text)
+	if err != nil {
+		return nil, p.errorf("%v", err)
+	}
+	p.i++
+	return &yamlNode{line.num, value}, nil
+}
+
+// parseChild parses the block node nested under the line at the parent
+// indent, or returns a null node if the next line is not more indented.
+func (p *yamlParser) parseChild(parentIndent, num int) (*yamlNode, error) {
+	if p.i < len(p.lines) && p.lines[p.i].indent > parentIndent {
+		return p.parse(p.lines[p.i].indent)
+	}
+	return &yamlNode{line: num}, nil
+}
+
+// parseSequence parses the "- item" lines at the indent.
+func (p *yamlParser) parseSequence(indent int) (*yamlNode, error) {
+	items := []*yamlNode{}
+	node := &yamlNode{p.lines[p.i].num, items}
+	for p.i < len(p.lines) && p.lines[p.i].indent == indent && isYAMLItem(p.lines[p.i].text) {
+		line := p.lines[p.i]
+		rest := strings.TrimLeft(line.text[1:], " ")
+		var item *yamlNode
+		var err error
+		if rest == "" {
+			p.i++
+			item, err = p.parseChild(indent, line.num)
+		} else {
+			// the item continues at the column after the dash, so an item
+			// mapping's later keys align with its first key
+			itemIndent := indent + len(line.text) - len(rest)
+			p.lines[p.i] = yamlLine{num: line.num, indent: itemIndent, text: rest}
+			item, err = p.parse(itemIndent)
+		}
+		if err != nil {
+			return nil, err
+		}
+		item.line = line.num
+		items = append(items, item)
+	}
+	node.value = items
+	return node, nil
+}
+
+// parseMapping parses the "key: value" lines at the indent.
+func (p *yamlParser) parseMapping(indent int) (*yamlNode, error) {
+	fields := make(map[string]*yamlNode)
+	node := &yamlNode{p.lines[p.i].num, fields}
+	for p.i < len(p.lines) && p.lines[p.i].indent == indent && !isYAMLItem(p.lines[p.i].text) {
+		line := p.lines[p.i]
+		key, rest, ok := splitYAMLKey(line.text)
+		if !ok {
+			return nil, p.errorf("expected key: value")
+		}
+		if _, ok := fields[key]; ok {
+			return nil, p.errorf("duplicate key %q", key)
+		}
+		if rest != "" {
+			value, err := yamlScalar(rest)
+			if err != nil {
+				return nil, p.errorf("%v", err)
+			}
+			fields[key] = &yamlNode{line.num, value}
+			p.i++
+			continue
+		}
+		p.i++
+		var value *yamlNode
+		var err error
+		if p.i < len(p.lines) && p.lines[p.i].indent == indent && isYAMLItem(p.lines[p.i].text) {
+			// sequences may be indented as much as their key
+			value, err = p.parseSequence(indent)
+		} else {
+			value, err = p.parseChild(indent, line.num)
+		}
+		if err != nil {
+			return nil, err
+		}
+		value.line = line.num
+		fields[key] = value
+	}
+	return node, nil
+}
+
+// isYAMLItem returns true if the line text is a sequence item.
+func isYAMLItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// splitYAMLKey splits the line text into a mapping key and the rest of the
+// line, at the first ": " or trailing ':' outside quotes.
+func splitYAMLKey(text string) (string, string, bool) {
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '{':
+			// flow collections are values, not keys
+			if i == 0 {
+				return "", "", false
+			}
+		case c == ':' && (i == len(text)-1 || text[i+1] == ' '):
+			key, err := yamlScalar(strings.TrimSpace(text[:i]))
+			if err != nil {
+				return "", "", false
+			}
+			name, ok := key.(string)
+			return name, strings.TrimSpace(text[i+1:]), ok
+		}
+	}
+	return "", "", false
+}
+
+// yamlScalar returns the value of a flow scalar or a single line flow
+// sequence.
+func yamlScalar(text string) (interface{}, error) {
+	switch {
+	case text == "" || text == "~" || text == "null":
+		return nil, nil
+	case text == "true" || text == "false":
+		return text == "true", nil
+	case text[0] == '"':
+		value, err := strconv.Unquote(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quoted string %s", text)
+		}
+		return value, nil
+	case text[0] == '\'':
+		if len(text) < 2 || text[len(text)-1] != '\'' {
+			return nil, fmt.Errorf("invalid quoted string %s", text)
+		}
+		return strings.Replace(text[1:len(text)-1], "''", "'", -1), nil
+	case text[0] == '[':
+		if text[len(text)-1] != ']' {
+			return nil, fmt.Errorf("unterminated flow sequence %s", text)
+		}
+		items := []interface{}{}
+		for _, item := range splitYAMLFlow(text[1 : len(text)-1]) {
+			value, err := yamlScalar(item)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, value)
+		}
+		return items, nil
+	case strings.IndexByte("{&*!|>%@`", text[0]) >= 0:
+		return nil, fmt.Errorf("unsupported YAML syntax %s", text)
+	case isYAMLNumber(text):
+		return strconv.ParseFloat(text, 64)
+	default:
+		return text, nil
+	}
+}
+
+// splitYAMLFlow splits the items of a flow sequence at commas outside
+// quotes. Nested flow collections are not supported.
+func splitYAMLFlow(text string) []string {
+	var items []string
+	var quote byte
+	var start int
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ',':
+			items = append(items, strings.TrimSpace(text[start:i]))
+			start = i + 1
+		}
+	}
+	if last := strings.TrimSpace(text[start:]); last != "" || len(items) > 0 {
+		items = append(items, last)
+	}
+	return items
+}
+
+// isYAMLNumber returns true if the text is a decimal number, with an
+// optional sign and fraction.
+func isYAMLNumber(text string) bool {
+	text = strings.TrimPrefix(strings.TrimPrefix(text, "-"), "+")
+	var digits, dots int
+	for _, r := range text {
+		switch {
+		case r >= '0' && r <= '9':
+			digits++
+		case r == '.':
+			dots++
+		default:
+			return false
+		}
+	}
+	return digits > 0 && dots <= 1
+}
+
+// plain returns the node value as plain maps, slices and scalars.
+func (node *yamlNode) plain() interface{} {
+	switch value := node.value.(type) {
+	case map[string]*yamlNode:
+		fields := make(map[string]interface{}, len(value))
+		for key, field := range value {
+			fields[key] = field.plain()
+		}
+		return fields
+	case []*yamlNode:
+		items := make([]interface{}, len(value))
+		for i, item := range value {
+			items[i] = item.plain()
+		}
+		return items
+	default:
+		return value
+	}
+}
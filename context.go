@@ -0,0 +1,11 @@
+package warp
+
+// contextKey is the type of keys for values ServeMux stores in request
+// contexts, so they cannot collide with keys defined in other packages.
+type contextKey int
+
+const (
+	variantKey  contextKey = iota // name of the Split variant
+	formatKey                     // format extension of the request
+	dispatchKey                   // dispatch of the request by ServeMux
+)
@@ -72,5 +72,17 @@ each verb (e.g. mux.Get(pattern, handler)).
 To register routes on a warp ServeMux directly, use the
 `ServeMux.Register(pattern string, handler http.Handler, rules ...Rule) *Route`
 method.
+
+Routes for the same pattern may serve different representations using
+content negotiation rules. The route offering the media type with the best
+Accept quality handles the request, and the mux responds with 406 Not
+Acceptable (or 415 Unsupported Media Type for ContentType rules) when no
+route fits.
+
+	func init() {
+		mux.Get("/notes", http.HandlerFunc(listJSONHandler)).Accept("application/json")
+		mux.Get("/notes", http.HandlerFunc(listHTMLHandler)).Accept("text/html")
+		mux.Post("/notes", http.HandlerFunc(createHandler)).ContentType("application/json")
+	}
 */
 package warp
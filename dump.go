@@ -0,0 +1,214 @@
+package warp
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// routeRow is the description of a Route in route tables and diffs.
+type routeRow struct {
+	host    string
+	pattern string   // path pattern
+	methods []string // allowed methods, nil if any
+	rules   string   // other rules and formats
+	name    string   // "name" metadata
+	handler string   // handler type or function name
+}
+
+// newRouteRow returns the description of the route.
+func newRouteRow(route *Route) routeRow {
+	host, pattern := splitPattern(route.pattern)
+	row := routeRow{
+		host:    host,
+		pattern: pattern,
+		methods: route.methods(),
+		name:    metaString(route, "name"),
+		handler: handlerName(route.handler),
+	}
+	var rules []string
+	for _, rule := range route.rules {
+		if _, ok := rule.(methodRule); !ok {
+			rules = append(rules, describeRule(rule))
+		}
+	}
+	if len(route.formats) > 0 {
+		rules = append(rules, "formats "+strings.Join(route.formats, ","))
+	}
+	if route.priority != 0 {
+		rules = append(rules, fmt.Sprintf("priority %d", route.priority))
+	}
+	row.rules = strings.Join(rules, "; ")
+	return row
+}
+
+// methodList returns the row methods, or "*" if any method is allowed.
+func (row routeRow) methodList() string {
+	if row.methods == nil {
+		return "*"
+	}
+	return strings.Join(row.methods, ",")
+}
+
+// routeRows returns the descriptions of the mux routes, sorted by host,
+// pattern and methods.
+func routeRows(mux *ServeMux) []routeRow {
+	var rows []routeRow
+	for _, route := range mux.Routes() {
+		rows = append(rows, newRouteRow(route))
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].host != rows[j].host {
+			return rows[i].host < rows[j].host
+		}
+		if rows[i].pattern != rows[j].pattern {
+			return rows[i].pattern < rows[j].pattern
+		}
+		return rows[i].methodList() < rows[j].methodList()
+	})
+	return rows
+}
+
+// anonymousFunc matches the runtime names of anonymous functions, such as
+// "pkg.init.func3", which change whenever the surrounding code is edited.
+var anonymousFunc = regexp.MustCompile(`\.func\d+(\.\d+)*$`)
+
+// handlerName returns the description of a fmt.Stringer handler, the name of
+// the named function a HandlerFunc adapts, or else the package qualified
+// type of the handler.
+func handlerName(handler http.Handler) string {
+	switch handler := handler.(type) {
+	case *variantHandler:
+		return "variant " + handler.name + " " + handlerName(handler.handler)
+	case *ServeMux:
+		// mounted muxes describe their whole route table
+		return fmt.Sprintf("%T", handler)
+	case fmt.Stringer:
+		// handlers may describe themselves, e.g. config redirects
+		return handler.String()
+	}
+	value := reflect.ValueOf(handler)
+	if value.Kind() == reflect.Func {
+		if fn := runtime.FuncForPC(value.Pointer()); fn != nil && !anonymousFunc.MatchString(fn.Name()) {
+			return fn.Name()
+		}
+	}
+	return fmt.Sprintf("%T", handler)
+}
+
+// Dump writes a table of the registered routes to w, sorted by host, pattern
+// and methods, with their other rules, "name" metadata (see Route.Meta) and
+// handler type.
+func (mux *ServeMux) Dump(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "HOST\tPATTERN\tMETHODS\tRULES\tNAME\tHANDLER")
+	for _, row := range routeRows(mux) {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", dash(row.host), row.pattern, row.methodList(), dash(row.rules), dash(row.name), row.handler)
+	}
+	return tw.Flush()
+}
+
+// String returns the table of registered routes written by Dump.
+func (mux *ServeMux) String() string {
+	var b strings.Builder
+	mux.Dump(&b)
+	return b.String()
+}
+
+// dash returns the value, or "-" if it is empty, for table columns.
+func dash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// RouteChange is a difference between the routes of two ServeMuxes, for a
+// pattern and method. Routes allowing any method have the method "*".
+type RouteChange struct {
+	Kind    string // "added", "removed" or "changed"
+	Pattern string // host and path pattern
+	Method  string
+	Old     string // description of the old routes, empty if added
+	New     string // description of the new routes, empty if removed
+}
+
+// String returns the change as a line for release notes, prefixed by "+",
+// "-" or "~" if the routes were added, removed or changed.
+func (change RouteChange) String() string {
+	switch change.Kind {
+	case "added":
+		return fmt.Sprintf("+ %s %s %s", change.Method, change.Pattern, change.New)
+	case "removed":
+		return fmt.Sprintf("- %s %s %s", change.Method, change.Pattern, change.Old)
+	default:
+		return fmt.Sprintf("~ %s %s %s -> %s", change.Method, change.Pattern, change.Old, change.New)
+	}
+}
+
+// Diff returns the routes added, removed and changed from the before mux to
+// the after mux, sorted by pattern and method. Routes are compared per
+// pattern and method, and the routes for a pattern and method changed if
+// their rules, "name" metadata or handler types changed. Handlers adapting
+// anonymous functions are compared by type, so give such routes a "name" to
+// tell their handlers apart.
+func Diff(before, after *ServeMux) []RouteChange {
+	oldRoutes, newRoutes := routesByMethod(before), routesByMethod(after)
+	var changes []RouteChange
+	for key, description := range oldRoutes {
+		change := RouteChange{Pattern: key[0], Method: key[1], Old: description}
+		switch newDescription, ok := newRoutes[key]; {
+		case !ok:
+			change.Kind = "removed"
+		case newDescription != description:
+			change.Kind, change.New = "changed", newDescription
+		default:
+			continue
+		}
+		changes = append(changes, change)
+	}
+	for key, description := range newRoutes {
+		if _, ok := oldRoutes[key]; !ok {
+			changes = append(changes, RouteChange{Kind: "added", Pattern: key[0], Method: key[1], New: description})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Pattern != changes[j].Pattern {
+			return changes[i].Pattern < changes[j].Pattern
+		}
+		return changes[i].Method < changes[j].Method
+	})
+	return changes
+}
+
+// routesByMethod returns the descriptions of the mux routes, keyed by their
+// pattern and each of their methods. Several routes for the same pattern and
+// method are described together, in registration order.
+func routesByMethod(mux *ServeMux) map[[2]string]string {
+	routes := make(map[[2]string]string)
+	for _, row := range routeRows(mux) {
+		description := fmt.Sprintf("[%s] %s", row.rules, row.handler)
+		if row.name != "" {
+			description = row.name + " " + description
+		}
+		methods := row.methods
+		if methods == nil {
+			methods = []string{"*"}
+		}
+		for _, method := range methods {
+			key := [2]string{row.host + row.pattern, method}
+			if previous, ok := routes[key]; ok {
+				routes[key] = previous + ", " + description
+				continue
+			}
+			routes[key] = description
+		}
+	}
+	return routes
+}
@@ -0,0 +1,85 @@
+package warp
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func reportsHandler(w http.ResponseWriter, r *http.Request) {}
+
+func TestDump(t *testing.T) {
+	mux := NewServeMux()
+	mux.Get("/reports/:id", http.HandlerFunc(reportsHandler)).Meta("name", "report").Accept("text/csv")
+	mux.Register("/reports/:id", stringHandler("edit")).Methods("PUT", "PATCH")
+	mux.Handle("/", stringHandler("root"))
+	mux.Post("api.example.com/upload", stringHandler("upload")).Priority(1)
+
+	expected := strings.Join([]string{
+		"HOST             PATTERN       METHODS    RULES                       NAME    HANDLER",
+		"-                /             *          -                           -       warp.stringHandler",
+		"-                /reports/:id  GET        warp.acceptRule [text/csv]  report  github.com/dghubble/warp.reportsHandler",
+		"-                /reports/:id  PUT,PATCH  -                           -       warp.stringHandler",
+		"api.example.com  /upload       POST       priority 1                  -       warp.stringHandler",
+		"",
+	}, "\n")
+	if dump := mux.String(); dump != expected {
+		t.Errorf("String() =\n%s\nwant\n%s", dump, expected)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	before := NewServeMux()
+	before.Get("/reports/:id", stringHandler("report"))
+	before.Register("/reports/:id", stringHandler("edit")).Methods("PUT", "DELETE")
+	before.Get("/legacy", stringHandler("legacy"))
+	before.Handle("/", stringHandler("root"))
+
+	after := NewServeMux()
+	after.Get("/reports/:id", stringHandler("report")).Accept("text/csv")
+	after.Register("/reports/:id", stringHandler("edit")).Methods("PUT")
+	after.Post("/reports", stringHandler("create"))
+	after.Handle("/", stringHandler("root"))
+
+	var changes []string
+	for _, change := range Diff(before, after) {
+		changes = append(changes, change.String())
+	}
+	expected := []string{
+		"- GET /legacy [] warp.stringHandler",
+		"+ POST /reports [] warp.stringHandler",
+		"- DELETE /reports/:id [] warp.stringHandler",
+		"~ GET /reports/:id [] warp.stringHandler -> [warp.acceptRule [text/csv]] warp.stringHandler",
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("Diff() =\n%s\nwant\n%s", strings.Join(changes, "\n"), strings.Join(expected, "\n"))
+	}
+	if changes := Diff(before, before); len(changes) != 0 {
+		t.Errorf("Diff(before, before) = %v, want no changes", changes)
+	}
+}
+
+// closureMux returns a mux with closure handlers, whose runtime names depend
+// on the number of closures preceding them.
+func closureMux(offset int) *ServeMux {
+	handlers := []http.HandlerFunc{
+		func(w http.ResponseWriter, r *http.Request) {},
+		func(w http.ResponseWriter, r *http.Request) {},
+		func(w http.ResponseWriter, r *http.Request) {},
+	}
+	mux := NewServeMux()
+	mux.Get("/notes", handlers[offset])
+	mux.Get("/reports", HandlerFunc(func(w http.ResponseWriter, r *http.Request) error { return nil })).Meta("name", "reports")
+	return mux
+}
+
+func TestDiffClosures(t *testing.T) {
+	if changes := Diff(closureMux(0), closureMux(1)); len(changes) != 0 {
+		t.Errorf("Diff() of closure handlers = %v, want no changes", changes)
+	}
+	expected := "- GET /reports reports [] warp.HandlerFunc"
+	if changes := Diff(closureMux(0), NewServeMux()); len(changes) != 2 || changes[1].String() != expected {
+		t.Errorf("Diff() = %v, want change %q", changes, expected)
+	}
+}
@@ -0,0 +1,171 @@
+package warp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io/fs"
+	"net/http"
+)
+
+// ErrNotFound may be returned, or wrapped, by a HandlerFunc to respond with
+// 404 Not Found.
+var ErrNotFound = errors.New("warp: not found")
+
+// HTTPError is an error with the HTTP status code of the response to it and
+// a message which is safe to show to clients:
+//
+//	return warp.HTTPError{Code: http.StatusBadRequest, Msg: "invalid note id"}
+type HTTPError struct {
+	Code int    // HTTP status code
+	Msg  string // message for the client, the status text if empty
+}
+
+func (err HTTPError) Error() string {
+	if err.Msg == "" {
+		return fmt.Sprintf("warp: %d %s", err.Code, http.StatusText(err.Code))
+	}
+	return fmt.Sprintf("warp: %d %s", err.Code, err.Msg)
+}
+
+// asHTTPError returns the HTTPError, or *HTTPError, in the error's chain.
+func asHTTPError(err error) (HTTPError, bool) {
+	var httpErr HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr, true
+	}
+	var httpErrPtr *HTTPError
+	if errors.As(err, &httpErrPtr) && httpErrPtr != nil {
+		return *httpErrPtr, true
+	}
+	return HTTPError{}, false
+}
+
+// ErrorHandler responds to an error serving a request.
+type ErrorHandler func(w http.ResponseWriter, req *http.Request, err error)
+
+// ErrorHandler sets the ErrorHandler which responds to errors serving
+// requests dispatched to the Route, instead of the mux's ErrorHandler:
+//
+//	mux.Get("/notes", notesPageHandler).ErrorHandler(warp.HTMLErrorHandler)
+func (route *Route) ErrorHandler(handler ErrorHandler) *Route {
+	route.errorHandler = handler
+	return route
+}
+
+// ServeError responds to the error serving the request with the
+// ErrorHandler of the Route the request was dispatched to, or else of the
+// ServeMux which dispatched it, or else with ProblemErrorHandler.
+func ServeError(w http.ResponseWriter, req *http.Request, err error) {
+	handler := ProblemErrorHandler
+	if d := requestDispatch(req); d != nil {
+		if d.route != nil && d.route.errorHandler != nil {
+			handler = d.route.errorHandler
+		} else if d.mux != nil && d.mux.ErrorHandler != nil {
+			handler = d.mux.ErrorHandler
+		}
+	}
+	handler(w, req, err)
+}
+
+// ErrorStatus returns the HTTP status code of the response to the error:
+// the Code of an HTTPError in its chain, 404 Not Found for ErrNotFound and
+// fs.ErrNotExist, 403 Forbidden for fs.ErrPermission, and otherwise 500
+// Internal Server Error. Recovered panics and HTTPErrors with invalid codes,
+// outside 200-999, are always 500 Internal Server Error.
+func ErrorStatus(err error) int {
+	var panicErr *PanicError
+	if errors.As(err, &panicErr) {
+		return http.StatusInternalServerError
+	}
+	if httpErr, ok := asHTTPError(err); ok {
+		if !validErrorCode(httpErr.Code) {
+			return http.StatusInternalServerError
+		}
+		return httpErr.Code
+	}
+	switch {
+	case errors.Is(err, ErrNotFound), errors.Is(err, fs.ErrNotExist):
+		return http.StatusNotFound
+	case errors.Is(err, fs.ErrPermission):
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
+
+// validErrorCode returns true if the code is a valid final HTTP status
+// code. Informational 1xx codes cannot respond to errors.
+func validErrorCode(code int) bool {
+	return code >= 200 && code <= 999
+}
+
+// errorMessage returns the message describing the error to clients, the Msg
+// of an HTTPError in its chain, or else the status text. Other errors may
+// describe internals, so are not shown to clients.
+func errorMessage(err error, status int) string {
+	if httpErr, ok := asHTTPError(err); ok && httpErr.Code == status && httpErr.Msg != "" {
+		return httpErr.Msg
+	}
+	return http.StatusText(status)
+}
+
+// TextErrorHandler responds to the error with its ErrorStatus and message as
+// plain text, as http.Error does. Only the messages of HTTPErrors are sent
+// to the client, other errors are described by the status text.
+func TextErrorHandler(w http.ResponseWriter, req *http.Request, err error) {
+	status := ErrorStatus(err)
+	http.Error(w, errorMessage(err, status), status)
+}
+
+// HTMLErrorHandler responds to the error with its ErrorStatus and a minimal
+// HTML page showing the status text and the message of an HTTPError.
+func HTMLErrorHandler(w http.ResponseWriter, req *http.Request, err error) {
+	status := ErrorStatus(err)
+	title := html.EscapeString(http.StatusText(status))
+	w.Header().Del("Content-Length")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<!DOCTYPE html>\n<title>%d %s</title>\n<h1>%s</h1>\n", status, title, title)
+	if message := errorMessage(err, status); message != http.StatusText(status) {
+		fmt.Fprintf(w, "<p>%s</p>\n", html.EscapeString(message))
+	}
+}
+
+// Problem is an RFC 7807 problem details object, describing an error in the
+// body of an HTTP response.
+type Problem struct {
+	Type     string `json:"type"`               // URI identifying the problem type
+	Title    string `json:"title"`              // summary of the problem type
+	Status   int    `json:"status"`             // HTTP status code
+	Detail   string `json:"detail,omitempty"`   // explanation of this occurrence
+	Instance string `json:"instance,omitempty"` // URI identifying this occurrence
+}
+
+// problem returns the Problem describing the error serving the request.
+func problem(req *http.Request, err error) *Problem {
+	status := ErrorStatus(err)
+	problem := &Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Instance: req.URL.Path,
+	}
+	if message := errorMessage(err, status); message != problem.Title {
+		problem.Detail = message
+	}
+	return problem
+}
+
+// ProblemErrorHandler responds to the error with its ErrorStatus and an
+// RFC 7807 application/problem+json body, with the message of an HTTPError
+// as its detail.
+func ProblemErrorHandler(w http.ResponseWriter, req *http.Request, err error) {
+	problem := problem(req, err)
+	w.Header().Del("Content-Length")
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(problem.Status)
+	json.NewEncoder(w).Encode(problem)
+}
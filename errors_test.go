@@ -0,0 +1,75 @@
+package warp
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestServeErrorHandlers(t *testing.T) {
+	err := errors.New("internal detail")
+	w := httptest.NewRecorder()
+	ProblemErrorHandler(w, newRequest("GET", "/notes/1"), err)
+	var problem Problem
+	if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("ProblemErrorHandler wrote %q: %v", w.Body.String(), err)
+	}
+	expected := Problem{Type: "about:blank", Title: "Internal Server Error", Status: 500, Instance: "/notes/1"}
+	if w.Code != 500 || !reflect.DeepEqual(problem, expected) {
+		t.Errorf("ProblemErrorHandler -> %d %+v, want 500 %+v", w.Code, problem, expected)
+	}
+
+	w = httptest.NewRecorder()
+	HTMLErrorHandler(w, newRequest("GET", "/"), err)
+	if w.Code != 500 || !strings.Contains(w.Body.String(), "<h1>Internal Server Error</h1>") {
+		t.Errorf("HTMLErrorHandler -> %d %q", w.Code, w.Body.String())
+	}
+
+	// requests not dispatched by a ServeMux
+	w = httptest.NewRecorder()
+	ServeError(w, newRequest("GET", "/"), err)
+	if w.Code != 500 || w.Header().Get("Content-Type") != "application/problem+json" {
+		t.Errorf("ServeError -> %d %q, want ProblemErrorHandler response", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	TextErrorHandler(w, newRequest("GET", "/"), err)
+	if w.Code != 500 || w.Body.String() != "Internal Server Error\n" {
+		t.Errorf("TextErrorHandler -> %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestRouteErrorHandlerPrecedence(t *testing.T) {
+	var used string
+	handler := func(name string) ErrorHandler {
+		return func(w http.ResponseWriter, req *http.Request, err error) {
+			used = name
+		}
+	}
+	serveError := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ServeError(w, req, errors.New("failed"))
+	})
+	mux := NewServeMux()
+	mux.ErrorHandler = handler("mux")
+	mux.Get("/route", serveError).ErrorHandler(handler("route"))
+	mux.Get("/mux", serveError)
+
+	cases := []struct {
+		url     string
+		handler string
+	}{
+		{"/route", "route"},
+		{"/mux", "mux"},
+	}
+	for _, c := range cases {
+		used = ""
+		mux.ServeHTTP(httptest.NewRecorder(), newRequest("GET", c.url))
+		if used != c.handler {
+			t.Errorf("GET %s used %q ErrorHandler, want %q", c.url, used, c.handler)
+		}
+	}
+}
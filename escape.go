@@ -0,0 +1,86 @@
+package warp
+
+import (
+	"net/url"
+	"strings"
+)
+
+// escapePattern returns the pattern path with its literal parts percent
+// encoded as by escapePath, so it can be matched against escaped request
+// paths. Param names, splats and optional group parens are kept as is,
+// while escaped parens, stars and backslashes are encoded as literals.
+func escapePattern(pattern string) string {
+	var escaped []rune
+	var literal []rune
+	rPattern := []rune(pattern)
+	for i := 0; i < len(rPattern); {
+		if splatName(rPattern, i) != "" {
+			// splat captures the remainder of the path
+			escaped = append(escaped, []rune(escapePath(string(literal)))...)
+			return string(append(escaped, rPattern[i:]...))
+		}
+		switch rPattern[i] {
+		case ':':
+			escaped = append(escaped, []rune(escapePath(string(literal)))...)
+			literal = literal[:0]
+			name, next, _ := captureName(rPattern, i+1)
+			escaped = append(escaped, ':')
+			escaped = append(escaped, []rune(name)...)
+			i = next
+		case '\\':
+			if i+1 < len(rPattern) && strings.ContainsRune("()\\*", rPattern[i+1]) {
+				i++
+			}
+			literal = append(literal, rPattern[i])
+			i++
+		case '(', ')':
+			escaped = append(escaped, []rune(escapePath(string(literal)))...)
+			literal = literal[:0]
+			escaped = append(escaped, rPattern[i])
+			i++
+		default:
+			literal = append(literal, rPattern[i])
+			i++
+		}
+	}
+	escaped = append(escaped, []rune(escapePath(string(literal)))...)
+	return string(escaped)
+}
+
+// escapePath returns the canonical escaped form of a path, in which each
+// segment between slashes is unescaped and then escaped again, so that
+// equivalent encodings of a path (e.g. "%41" and "A") compare equal while
+// encoded slashes ("%2F") remain part of their segment.
+func escapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if unescaped, err := url.PathUnescape(segment); err == nil {
+			segments[i] = url.PathEscape(unescaped)
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// unescapePath returns the unescaped form of an escaped path, or the path
+// itself if it is not validly escaped.
+func unescapePath(path string) string {
+	unescaped, err := url.PathUnescape(path)
+	if err != nil {
+		return path
+	}
+	return unescaped
+}
+
+// unescapeParams returns the params with each value unescaped individually.
+func unescapeParams(params url.Values) url.Values {
+	if len(params) == 0 {
+		return params
+	}
+	unescaped := make(url.Values, len(params))
+	for name, values := range params {
+		for _, value := range values {
+			unescaped.Add(name, unescapePath(value))
+		}
+	}
+	return unescaped
+}
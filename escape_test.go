@@ -0,0 +1,80 @@
+package warp
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+// test matching escaped paths
+
+func TestEscapePattern(t *testing.T) {
+	cases := map[string]string{
+		"/files/:name":       "/files/:name",
+		"/你好/:名":             "/%E4%BD%A0%E5%A5%BD/:名",
+		"/a b/:file.:ext":    "/a%20b/:file.:ext",
+		"/docs/%2F/":         "/docs/%2F/",
+		"/begin/:start/end/": "/begin/:start/end/",
+		`/files/\*files`:     "/files/%2Afiles",
+		"/files/*path":       "/files/*path",
+	}
+	for pattern, want := range cases {
+		if escaped := escapePattern(pattern); escaped != want {
+			t.Errorf("escapePattern(%q) = %q, want %q", pattern, escaped, want)
+		}
+	}
+}
+
+func registerEscapedRoutes(mux *ServeMux) {
+	mux.Get("/files/:name", stringHandler("file"))
+	mux.Get("/files/:dir/:name", stringHandler("nested file"))
+	mux.Get("/你好/:名", stringHandler("你好"))
+	mux.Get("/docs/", stringHandler("docs"))
+}
+
+var escapedPathTests = []struct {
+	escaped  bool   // whether the mux uses escaped paths
+	url      string // test request url
+	code     int    // expected HTTP response code
+	result   string // expected handler result
+	location string // expected redirect location
+	params   url.Values
+}{
+	{false, "/files/a%2Fb", 200, "nested file", "", url.Values{":dir": {"a"}, ":name": {"b"}}},
+	{true, "/files/a%2Fb", 200, "file", "", url.Values{":name": {"a/b"}}},
+	{true, "/files/a%20b", 200, "file", "", url.Values{":name": {"a b"}}},
+	{true, "/files/x%2Fy/z", 200, "nested file", "", url.Values{":dir": {"x/y"}, ":name": {"z"}}},
+	// equivalent encodings match
+	{true, "/%E4%BD%A0%E5%A5%BD/%E4%B8%96", 200, "你好", "", url.Values{":名": {"世"}}},
+	{true, "/你好/tim", 200, "你好", "", url.Values{":名": {"tim"}}},
+	{true, "/f%69les/x", 200, "file", "", url.Values{":name": {"x"}}},
+	// cleaning never rewrites encoded segments
+	{true, "/files/..%2Fetc", 200, "file", "", url.Values{":name": {"../etc"}}},
+	{true, "/docs/../files/a%2Fb", 301, "", "/files/a%2Fb", emptyParams},
+	{true, "/docs", 301, "", "/docs/", emptyParams},
+}
+
+func TestServeHTTPEscapedPaths(t *testing.T) {
+	for _, et := range escapedPathTests {
+		mux := NewServeMux()
+		mux.UseEscapedPath = et.escaped
+		registerEscapedRoutes(mux)
+
+		r := newRequest("GET", et.url)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != et.code {
+			t.Errorf("escaped %t GET %s -> code %d, want %d", et.escaped, et.url, w.Code, et.code)
+		}
+		if result := w.Header().Get("Result"); result != et.result {
+			t.Errorf("escaped %t GET %s -> result %q, want %q", et.escaped, et.url, result, et.result)
+		}
+		if location := w.Header().Get("Location"); location != et.location {
+			t.Errorf("escaped %t GET %s -> location %q, want %q", et.escaped, et.url, location, et.location)
+		}
+		if !reflect.DeepEqual(r.URL.Query(), et.params) {
+			t.Errorf("escaped %t GET %s -> params %v, want %v", et.escaped, et.url, r.URL.Query(), et.params)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package warp
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Explanation traces how a ServeMux matched a request: every route it
+// considered, whether the route's pattern matched, which of its Rules passed,
+// and why the chosen route was preferred over the others.
+type Explanation struct {
+	Method   string        `json:"method"`
+	URL      string        `json:"url"`
+	Host     string        `json:"host,omitempty"` // normalized request host
+	Path     string        `json:"path"`           // path matched against patterns
+	Routes   []*RouteTrace `json:"routes"`
+	Outcome  string        `json:"outcome"`            // "route", "redirect", "rejected" or "not found"
+	Pattern  string        `json:"pattern,omitempty"`  // pattern Handler would report
+	Status   int           `json:"status,omitempty"`   // status of redirects and rejections
+	Location string        `json:"location,omitempty"` // redirect location
+}
+
+// RouteTrace describes how a Route was considered for a request.
+type RouteTrace struct {
+	Pattern   string      `json:"pattern"`
+	Matched   bool        `json:"matched"`             // whether the host and path matched the pattern
+	Twin      bool        `json:"twin,omitempty"`      // matched once a trailing slash was added or removed
+	Format    string      `json:"format,omitempty"`    // format extension stripped from the path to match
+	RuneCount int         `json:"runeCount,omitempty"` // num runes the pattern matched
+	Priority  int         `json:"priority,omitempty"`
+	Quality   float64     `json:"quality,omitempty"` // content negotiation quality
+	Rules     []RuleTrace `json:"rules,omitempty"`   // rules evaluated, in order
+	Allowed   bool        `json:"allowed"`           // whether the route was a candidate
+	Chosen    bool        `json:"chosen"`
+	Reason    string      `json:"reason,omitempty"` // why the route was not chosen
+
+	candidate candidate
+}
+
+// RuleTrace describes a Rule evaluated for a request.
+type RuleTrace struct {
+	Rule   string `json:"rule"`
+	Passed bool   `json:"passed"`
+}
+
+// Explain returns the trace of how the mux matches the request, without
+// dispatching it. The request is not modified.
+func (mux *ServeMux) Explain(request *http.Request) *Explanation {
+	explanation := &Explanation{
+		Method: request.Method,
+		URL:    request.URL.String(),
+		Host:   requestHost(request),
+		Path:   request.URL.Path,
+	}
+	if mux.UseEscapedPath {
+		explanation.Path = escapePath(request.URL.EscapedPath())
+	}
+	if request.Method != "CONNECT" {
+		explanation.Path = cleanPath(explanation.Path)
+	}
+	d := mux.reqHandler(request, explanation)
+	explanation.Pattern = d.pattern
+	switch handler := d.handler.(type) {
+	case *Rejection:
+		explanation.Outcome, explanation.Status = "rejected", handler.status()
+	default:
+		switch {
+		case d.route != nil:
+			explanation.Outcome = "route"
+		case d.redirect:
+			w := &headerWriter{header: make(http.Header)}
+			d.handler.ServeHTTP(w, request)
+			explanation.Outcome, explanation.Status = "redirect", w.status
+			explanation.Location = w.header.Get("Location")
+		default:
+			explanation.Outcome, explanation.Status = "not found", http.StatusNotFound
+		}
+	}
+	return explanation
+}
+
+// size returns the number of routes traced so far.
+func (e *Explanation) size() int {
+	if e == nil {
+		return 0
+	}
+	return len(e.Routes)
+}
+
+// unmatched traces a route whose pattern did not match the request.
+func (e *Explanation) unmatched(route *Route) {
+	if e == nil {
+		return
+	}
+	e.Routes = append(e.Routes, &RouteTrace{
+		Pattern:  route.pattern,
+		Priority: route.priority,
+		Reason:   "pattern does not match",
+	})
+}
+
+// twin traces a trailing slash twin redirect candidate, whose route rules
+// are not evaluated.
+func (e *Explanation) twin(c candidate) {
+	if e == nil {
+		return
+	}
+	e.Routes = append(e.Routes, &RouteTrace{
+		Pattern:   c.route.pattern,
+		Matched:   true,
+		Twin:      true,
+		RuneCount: c.runeCount,
+		Priority:  c.route.priority,
+		Allowed:   true,
+		candidate: c,
+	})
+}
+
+// evaluated traces a route whose pattern matched, the first passed rules of
+// which passed before the failed rule, if any, rejected the request.
+func (e *Explanation) evaluated(c candidate, rules []Rule, passed int, failed Rule) {
+	if e == nil {
+		return
+	}
+	trace := &RouteTrace{
+		Pattern:   c.route.pattern,
+		Matched:   true,
+		Twin:      c.twin,
+		Format:    c.extension,
+		RuneCount: c.runeCount,
+		Priority:  c.route.priority,
+		Quality:   c.quality,
+		Allowed:   failed == nil,
+		candidate: c,
+	}
+	for _, rule := range rules[:passed] {
+		trace.Rules = append(trace.Rules, RuleTrace{describeRule(rule), true})
+	}
+	if failed != nil {
+		trace.Rules = append(trace.Rules, RuleTrace{describeRule(failed), false})
+		trace.Reason = "rejected by " + describeRule(failed)
+	}
+	e.Routes = append(e.Routes, trace)
+}
+
+// choose marks the best candidate among the routes traced since first and
+// records why it was preferred over the other candidates. The traced routes
+// are sorted by pattern, keeping the registration order of each pattern's
+// routes.
+func (e *Explanation) choose(best *candidate, first int) {
+	if e == nil {
+		return
+	}
+	traces := e.Routes[first:]
+	sort.SliceStable(traces, func(i, j int) bool {
+		return traces[i].Pattern < traces[j].Pattern
+	})
+	for _, trace := range traces {
+		if !trace.Allowed || best.route == nil {
+			continue
+		}
+		if trace.candidate.route == best.route && trace.candidate.twin == best.twin {
+			trace.Chosen = true
+			continue
+		}
+		_, measure := trace.candidate.compare(best)
+		trace.Reason = "lost to " + best.route.pattern + " on " + measure
+	}
+}
+
+// describeRule returns a description of the rule for traces, such as
+// "warp.methodRule [GET]".
+func describeRule(rule Rule) string {
+	switch rule := rule.(type) {
+	case fmt.Stringer:
+		return rule.String()
+	case *rejectRule:
+		return describeRule(rule.Rule)
+	}
+	switch reflect.ValueOf(rule).Kind() {
+	case reflect.Slice, reflect.Array, reflect.String, reflect.Int, reflect.Bool:
+		return fmt.Sprintf("%T %v", rule, rule)
+	default:
+		return fmt.Sprintf("%T", rule)
+	}
+}
+
+// headerWriter is a ResponseWriter which records the status and header of
+// a response, discarding its body.
+type headerWriter struct {
+	header http.Header
+	status int
+}
+
+func (w *headerWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *headerWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return len(b), nil
+}
+
+func (w *headerWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+// ExplainHandler returns a debug handler which explains how the mux matches
+// the request described by its query params: the method (GET by default),
+// the url, and any number of "Name: value" header params. Relative urls are
+// explained on the debug request's host. The Explanation is rendered as
+// HTML, or as JSON if the Accept header prefers application/json or the
+// format param is "json".
+//
+//	debug.Handle("/debug/routes", warp.ExplainHandler(mux))
+//	// GET /debug/routes?method=POST&url=/reports/42&header=Accept:+text/csv
+func ExplainHandler(mux *ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		method := query.Get("method")
+		if method == "" {
+			method = "GET"
+		}
+		target := query.Get("url")
+		if target == "" {
+			target = "/"
+		}
+		explained, err := http.NewRequest(strings.ToUpper(method), target, nil)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if explained.Host == "" {
+			explained.Host = r.Host
+		}
+		for _, header := range query["header"] {
+			name, value, ok := strings.Cut(header, ":")
+			if !ok {
+				http.Error(w, "invalid header "+header, http.StatusBadRequest)
+				return
+			}
+			explained.Header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+		}
+		explanation := mux.Explain(explained)
+
+		ranges := parseAccept(r.Header.Get("Accept"))
+		if query.Get("format") == "json" || acceptQuality(ranges, "application/json") > acceptQuality(ranges, "text/html") {
+			w.Header().Set("Content-Type", "application/json")
+			encoder := json.NewEncoder(w)
+			encoder.SetIndent("", "  ")
+			encoder.Encode(explanation)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		explainTemplate.Execute(w, explanation)
+	})
+}
+
+var explainTemplate = template.Must(template.New("explain").Parse(`<!DOCTYPE html>
+<html>
+<head><title>{{.Method}} {{.URL}}</title></head>
+<body>
+<h1>{{.Method}} {{.URL}}</h1>
+<p>Host {{.Host}}, path {{.Path}}: {{.Outcome}}{{with .Pattern}} {{.}}{{end}}{{with .Status}} ({{.}}){{end}}{{with .Location}} to {{.}}{{end}}</p>
+<table>
+<tr><th>Pattern</th><th>Matched</th><th>Runes</th><th>Priority</th><th>Quality</th><th>Rules</th><th>Chosen</th><th>Reason</th></tr>
+{{range .Routes}}<tr>
+<td>{{.Pattern}}{{if .Twin}} (twin){{end}}{{with .Format}} (.{{.}}){{end}}</td>
+<td>{{.Matched}}</td>
+<td>{{.RuneCount}}</td>
+<td>{{.Priority}}</td>
+<td>{{.Quality}}</td>
+<td>{{range .Rules}}{{if .Passed}}&#10003;{{else}}&#10007;{{end}} {{.Rule}}<br>{{end}}</td>
+<td>{{.Chosen}}</td>
+<td>{{.Reason}}</td>
+</tr>
+{{end}}</table>
+</body>
+</html>
+`))
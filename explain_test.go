@@ -0,0 +1,135 @@
+package warp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func explainMux() *ServeMux {
+	mux := NewServeMux()
+	mux.Get("/notes/:id", stringHandler("note"))
+	mux.Get("/notes/new", stringHandler("new"))
+	mux.Post("/notes/new", stringHandler("create"))
+	mux.Get("/docs/", stringHandler("docs"))
+	mux.Get("/about", stringHandler("about"))
+	return mux
+}
+
+// traceOf returns the first route trace for the pattern.
+func traceOf(explanation *Explanation, pattern string) *RouteTrace {
+	for _, trace := range explanation.Routes {
+		if trace.Pattern == pattern {
+			return trace
+		}
+	}
+	return nil
+}
+
+func TestExplain(t *testing.T) {
+	mux := explainMux()
+	explanation := mux.Explain(newRequest("GET", "/notes/new"))
+
+	if explanation.Outcome != "route" || explanation.Pattern != "/notes/new" || explanation.Path != "/notes/new" {
+		t.Errorf("Explain() = %s %s %s, want route /notes/new", explanation.Outcome, explanation.Pattern, explanation.Path)
+	}
+	if len(explanation.Routes) != 5 {
+		t.Fatalf("Explain() traced %d routes, want 5", len(explanation.Routes))
+	}
+	// routes are sorted by pattern, in registration order per pattern
+	get, post := explanation.Routes[3], explanation.Routes[4]
+	if get.Pattern != "/notes/new" || !get.Chosen || !get.Allowed || get.RuneCount != 10 {
+		t.Errorf("Explain() GET /notes/new trace %+v, want chosen", get)
+	}
+	if post.Pattern != "/notes/new" || post.Chosen || post.Allowed {
+		t.Errorf("Explain() POST /notes/new trace %+v, want rejected", post)
+	}
+	if len(post.Rules) != 1 || post.Rules[0] != (RuleTrace{"warp.methodRule [POST]", false}) || post.Reason != "rejected by warp.methodRule [POST]" {
+		t.Errorf("Explain() POST /notes/new rules %v, reason %q", post.Rules, post.Reason)
+	}
+	if param := traceOf(explanation, "/notes/:id"); param.Chosen || param.Reason != "lost to /notes/new on runes matched" {
+		t.Errorf("Explain() /notes/:id reason %q", param.Reason)
+	}
+	if about := traceOf(explanation, "/about"); about.Matched || about.Reason != "pattern does not match" {
+		t.Errorf("Explain() /about trace %+v, want unmatched", about)
+	}
+}
+
+var explainOutcomeTests = []struct {
+	method   string
+	url      string
+	outcome  string
+	pattern  string
+	status   int
+	location string
+}{
+	{"GET", "/docs", "redirect", "/docs/", 301, "/docs/"},
+	{"GET", "/notes/../about", "redirect", "/about", 301, "/about"},
+	{"DELETE", "/notes/new", "rejected", "", 404, ""},
+	{"GET", "/missing", "not found", "", 404, ""},
+}
+
+func TestExplainOutcomes(t *testing.T) {
+	mux := explainMux()
+	for _, et := range explainOutcomeTests {
+		r := newRequest(et.method, et.url)
+		explanation := mux.Explain(r)
+		if explanation.Outcome != et.outcome || explanation.Pattern != et.pattern || explanation.Status != et.status || explanation.Location != et.location {
+			t.Errorf("Explain(%s %s) = %s %q %d %q, want %s %q %d %q", et.method, et.url, explanation.Outcome, explanation.Pattern, explanation.Status, explanation.Location, et.outcome, et.pattern, et.status, et.location)
+		}
+	}
+	// twin redirects are traced without evaluating rules
+	mux.Post("/docs/", stringHandler("upload docs"))
+	explanation := mux.Explain(newRequest("GET", "/docs"))
+	if docs := traceOf(explanation, "/docs/"); !docs.Twin || !docs.Chosen || docs.Rules != nil {
+		t.Errorf("Explain(GET /docs) /docs/ trace %+v, want chosen twin", docs)
+	}
+	// and the routes of the twin are not also traced as unmatched
+	for _, trace := range explanation.Routes {
+		if trace.Pattern == "/docs/" && !trace.Twin {
+			t.Errorf("Explain(GET /docs) /docs/ traced as %+v, want only the twin", trace)
+		}
+	}
+}
+
+func TestExplainHandler(t *testing.T) {
+	mux := explainMux()
+	handler := ExplainHandler(mux)
+	query := url.Values{
+		"method": {"post"},
+		"url":    {"/notes/new"},
+		"header": {"Accept: application/json"},
+	}
+
+	r := newRequest("GET", "/debug/routes?"+query.Encode())
+	r.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	var explanation Explanation
+	if err := json.Unmarshal(w.Body.Bytes(), &explanation); err != nil {
+		t.Fatalf("ExplainHandler JSON error %v: %s", err, w.Body.String())
+	}
+	if explanation.Method != "POST" || explanation.Outcome != "route" || explanation.Pattern != "/notes/new" {
+		t.Errorf("ExplainHandler JSON = %+v, want POST route /notes/new", explanation)
+	}
+
+	r = newRequest("GET", "/debug/routes?"+query.Encode())
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if contentType := w.Header().Get("Content-Type"); contentType != "text/html; charset=utf-8" {
+		t.Errorf("ExplainHandler Content-Type %q, want HTML", contentType)
+	}
+	if body := w.Body.String(); !strings.Contains(body, "<h1>POST /notes/new</h1>") || !strings.Contains(body, "rejected by warp.methodRule [GET]") {
+		t.Errorf("ExplainHandler HTML missing trace: %s", body)
+	}
+
+	r = newRequest("GET", "/debug/routes?header=invalid")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("ExplainHandler invalid header -> %d, want 400", w.Code)
+	}
+}
@@ -0,0 +1,129 @@
+package warp
+
+import (
+	"context"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// Formats sets the format extensions (e.g. "json", "csv") the Route serves.
+// A request path whose last segment ends in one of the extensions, as in
+// /reports/42.json, is matched with the extension stripped, before params
+// are captured. Requests without an extension, as in /reports/42, are
+// matched as usual and their format is negotiated from the Accept header,
+// defaulting to the first format. If the Accept header accepts none of the
+// formats, the request is rejected with 406 Not Acceptable. Handlers read
+// the format with RequestFormat:
+//
+//	mux.Get("/reports/:id", reportHandler).Formats("json", "csv")
+func (route *Route) Formats(extensions ...string) *Route {
+	for _, extension := range extensions {
+		extension = strings.ToLower(strings.TrimPrefix(extension, "."))
+		route.formats = append(route.formats, extension)
+		if mediaType := formatMediaType(extension); mediaType != "" {
+			route.formatTypes = append(route.formatTypes, mediaType)
+		}
+	}
+	return route
+}
+
+// negotiateFormat returns the route format best accepted by the request.
+// If the request has an Accept header which accepts none of the formats,
+// returns the acceptRule which rejects the request instead.
+func (route *Route) negotiateFormat(request *http.Request) (string, Rule) {
+	accept := request.Header["Accept"]
+	if len(accept) == 0 || len(route.formatTypes) == 0 {
+		return route.formats[0], nil
+	}
+	ranges := parseAccept(strings.Join(accept, ","))
+	var format string
+	var quality float64
+	for _, extension := range route.formats {
+		if q := acceptQuality(ranges, formatMediaType(extension)); q > quality {
+			format, quality = extension, q
+		}
+	}
+	if quality == 0 {
+		return "", acceptRule(route.formatTypes)
+	}
+	return format, nil
+}
+
+// formatTypes are the media types of common format extensions, so routes
+// negotiate the same types on every host, whatever its mime.types files.
+var formatTypes = map[string]string{
+	"atom": "application/atom+xml",
+	"css":  "text/css",
+	"csv":  "text/csv",
+	"gif":  "image/gif",
+	"htm":  "text/html",
+	"html": "text/html",
+	"ics":  "text/calendar",
+	"jpeg": "image/jpeg",
+	"jpg":  "image/jpeg",
+	"js":   "text/javascript",
+	"json": "application/json",
+	"md":   "text/markdown",
+	"pdf":  "application/pdf",
+	"png":  "image/png",
+	"rss":  "application/rss+xml",
+	"svg":  "image/svg+xml",
+	"txt":  "text/plain",
+	"wasm": "application/wasm",
+	"webp": "image/webp",
+	"xml":  "application/xml",
+	"yaml": "application/yaml",
+	"yml":  "application/yaml",
+	"zip":  "application/zip",
+}
+
+// formatMediaType returns the media type, without params, of the format
+// extension, from formatTypes or else the mime package's registry, or an
+// empty string if unknown.
+func formatMediaType(extension string) string {
+	if mediaType, ok := formatTypes[extension]; ok {
+		return mediaType
+	}
+	mediaType, _, err := mime.ParseMediaType(mime.TypeByExtension("." + extension))
+	if err != nil {
+		return ""
+	}
+	return mediaType
+}
+
+// splitFormat splits the extension from the last segment of the path,
+// returning the stripped path and the lowercased extension. Returns the path
+// and an empty extension if the last segment has no extension.
+func splitFormat(path string) (string, string) {
+	slash := strings.LastIndex(path, "/")
+	dot := strings.LastIndex(path, ".")
+	if dot <= slash+1 || dot == len(path)-1 {
+		return path, ""
+	}
+	return path[:dot], strings.ToLower(path[dot+1:])
+}
+
+// hasFormat returns true if any of the routes serves the format extension.
+func hasFormat(routes []*Route, extension string) bool {
+	for _, route := range routes {
+		if contains(route.formats, extension) {
+			return true
+		}
+	}
+	return false
+}
+
+// withFormat returns a shallow copy of the request whose context carries
+// the format.
+func withFormat(request *http.Request, format string) *http.Request {
+	return request.WithContext(context.WithValue(request.Context(), formatKey, format))
+}
+
+// RequestFormat returns the format extension (e.g. "json") of the request,
+// as stripped from its path or negotiated by a Route with Formats, or an
+// empty string if the matched Route has no Formats.
+func RequestFormat(request *http.Request) string {
+	format, _ := request.Context().Value(formatKey).(string)
+	return format
+}
@@ -0,0 +1,119 @@
+package warp
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// formatHandler writes the request format and its :id param
+func formatHandler(name string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s %s %s", name, RequestFormat(r), r.URL.Query().Get(":id"))
+	})
+}
+
+var formatTests = []struct {
+	url    string // test request url
+	accept string // Accept header
+	code   int    // expected HTTP response code
+	body   string // expected response body
+}{
+	// extensions are stripped before params are captured
+	{"/reports/42", "", 200, "report json 42"},
+	{"/reports/42.json", "", 200, "report json 42"},
+	{"/reports/42.csv", "", 200, "report csv 42"},
+	{"/reports/42.CSV", "", 200, "report csv 42"},
+	// unlisted extensions are captured in the param
+	{"/reports/42.xml", "", 200, "report json 42.xml"},
+	// requests without an extension negotiate the format
+	{"/reports/42", "text/csv", 200, "report csv 42"},
+	{"/reports/42", "application/json;q=0.5, text/csv;q=0.9", 200, "report csv 42"},
+	{"/reports/42", "*/*", 200, "report json 42"},
+	{"/reports/42", "image/png", 406, "not acceptable: application/json, text/csv\n"},
+	// explicit extensions are not negotiated
+	{"/reports/42.json", "image/png", 200, "report json 42"},
+	// literal patterns with extensions take precedence
+	{"/reports/latest.json", "", 200, "latest  "},
+	{"/reports/latest", "", 200, "report json latest"},
+	// routes without Formats match extensions literally
+	{"/files/a.json", "", 200, "file  a.json"},
+}
+
+func TestFormats(t *testing.T) {
+	mux := NewServeMux()
+	mux.Get("/reports/:id", formatHandler("report")).Formats("json", ".CSV")
+	mux.Get("/reports/latest.json", formatHandler("latest"))
+	mux.Get("/files/:id", formatHandler("file"))
+
+	for _, ft := range formatTests {
+		r := newRequest("GET", ft.url)
+		if ft.accept != "" {
+			r.Header.Set("Accept", ft.accept)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != ft.code {
+			t.Errorf("GET %s %q -> code %d, want %d", ft.url, ft.accept, w.Code, ft.code)
+		}
+		if body := w.Body.String(); body != ft.body {
+			t.Errorf("GET %s %q -> body %q, want %q", ft.url, ft.accept, body, ft.body)
+		}
+	}
+}
+
+func TestFormatCanonicalRedirect(t *testing.T) {
+	mux := NewServeMux()
+	mux.CaseInsensitive = true
+	mux.CanonicalRedirect = true
+	mux.Get("/reports/:id", formatHandler("report")).Formats("json")
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, newRequest("GET", "/REPORTS/Q1.json"))
+	if location := w.Header().Get("Location"); w.Code != 301 || location != "/reports/Q1.json" {
+		t.Errorf("GET /REPORTS/Q1.json -> %d %q, want 301 %q", w.Code, location, "/reports/Q1.json")
+	}
+}
+
+var splitFormatTests = []struct {
+	path      string
+	stripped  string
+	extension string
+}{
+	{"/reports/42.json", "/reports/42", "json"},
+	{"/reports/42.tar.GZ", "/reports/42.tar", "gz"},
+	{"/reports/42", "/reports/42", ""},
+	{"/reports/.json", "/reports/.json", ""},
+	{"/reports/42.", "/reports/42.", ""},
+	{"/v1.2/reports", "/v1.2/reports", ""},
+	{"/reports/", "/reports/", ""},
+}
+
+func TestSplitFormat(t *testing.T) {
+	for _, st := range splitFormatTests {
+		stripped, extension := splitFormat(st.path)
+		if stripped != st.stripped || extension != st.extension {
+			t.Errorf("splitFormat(%q) = %q, %q, want %q, %q", st.path, stripped, extension, st.stripped, st.extension)
+		}
+	}
+}
+
+func TestFormatMediaType(t *testing.T) {
+	cases := map[string]string{
+		// built in, independent of the host's mime.types
+		"json": "application/json",
+		"csv":  "text/csv",
+		"yaml": "application/yaml",
+		"yml":  "application/yaml",
+		"txt":  "text/plain",
+		"js":   "text/javascript",
+		// unknown
+		"warp": "",
+	}
+	for extension, expected := range cases {
+		if mediaType := formatMediaType(extension); mediaType != expected {
+			t.Errorf("formatMediaType(%q) = %q, want %q", extension, mediaType, expected)
+		}
+	}
+}
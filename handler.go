@@ -0,0 +1,72 @@
+package warp
+
+import (
+	"net/http"
+)
+
+// HandlerFunc is an http.Handler which returns an error, rather than
+// responding to it. Returned errors are passed to ServeError, which responds
+// with the ErrorHandler of the route or ServeMux, with a status code chosen
+// by ErrorStatus:
+//
+//	mux.GetE("/notes/:id", func(w http.ResponseWriter, req *http.Request) error {
+//		note, err := store.Note(req.URL.Query().Get(":id"))
+//		if err != nil {
+//			return err
+//		}
+//		return json.NewEncoder(w).Encode(note)
+//	})
+//
+// Without an ErrorHandler, errors are responded to with problem details, as
+// by ProblemErrorHandler. Programs serving other formats set one instead:
+//
+//	mux.ErrorHandler = warp.HTMLErrorHandler
+//
+// Errors should be returned before writing the response, which cannot be
+// replaced once written. A HandlerFunc may be registered with Register or
+// any other method accepting an http.Handler.
+type HandlerFunc func(http.ResponseWriter, *http.Request) error
+
+// ServeHTTP calls f(w, req), and passes the error it returns, if any, to
+// ServeError.
+func (f HandlerFunc) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if err := f(w, req); err != nil {
+		ServeError(w, req, err)
+	}
+}
+
+// HeadE registers the error-returning handler for the pattern and HEAD
+// requests only. Returns the new Route entry.
+func (mux *ServeMux) HeadE(pattern string, handler HandlerFunc) *Route {
+	return mux.Head(pattern, handler)
+}
+
+// GetE registers the error-returning handler for the pattern and GET
+// requests only. Returns the new Route entry.
+func (mux *ServeMux) GetE(pattern string, handler HandlerFunc) *Route {
+	return mux.Get(pattern, handler)
+}
+
+// PostE registers the error-returning handler for the pattern and POST
+// requests only. Returns the new Route entry.
+func (mux *ServeMux) PostE(pattern string, handler HandlerFunc) *Route {
+	return mux.Post(pattern, handler)
+}
+
+// PutE registers the error-returning handler for the pattern and PUT
+// requests only. Returns the new Route entry.
+func (mux *ServeMux) PutE(pattern string, handler HandlerFunc) *Route {
+	return mux.Put(pattern, handler)
+}
+
+// DeleteE registers the error-returning handler for the pattern and DELETE
+// requests only. Returns the new Route entry.
+func (mux *ServeMux) DeleteE(pattern string, handler HandlerFunc) *Route {
+	return mux.Delete(pattern, handler)
+}
+
+// OptionsE registers the error-returning handler for the pattern and
+// OPTIONS requests only. Returns the new Route entry.
+func (mux *ServeMux) OptionsE(pattern string, handler HandlerFunc) *Route {
+	return mux.Options(pattern, handler)
+}
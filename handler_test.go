@@ -0,0 +1,111 @@
+package warp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func errorHandler(err error) HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) error {
+		return err
+	}
+}
+
+func TestHandlerFunc(t *testing.T) {
+	mux := NewServeMux()
+	mux.ErrorHandler = TextErrorHandler
+	mux.GetE("/ok", func(w http.ResponseWriter, req *http.Request) error {
+		_, err := w.Write([]byte("ok"))
+		return err
+	})
+	mux.GetE("/bad", errorHandler(HTTPError{Code: http.StatusBadRequest, Msg: "invalid id"}))
+	mux.GetE("/gone", errorHandler(&HTTPError{Code: http.StatusGone}))
+	mux.GetE("/missing", errorHandler(fmt.Errorf("note 7: %w", ErrNotFound)))
+	mux.GetE("/file", errorHandler(&fs.PathError{Op: "open", Path: "notes.txt", Err: fs.ErrNotExist}))
+	mux.GetE("/invalid", errorHandler(HTTPError{Code: 42, Msg: "invalid code"}))
+	mux.GetE("/secret", errorHandler(errors.New("db password rejected")))
+	mux.Register("/registered", errorHandler(fs.ErrPermission), NewMethodRule("POST"))
+
+	cases := []struct {
+		method string
+		url    string
+		code   int
+		body   string
+	}{
+		{"GET", "/ok", 200, "ok"},
+		{"GET", "/bad", 400, "invalid id\n"},
+		{"GET", "/gone", 410, "Gone\n"},
+		{"GET", "/missing", 404, "Not Found\n"},
+		{"GET", "/file", 404, "Not Found\n"},
+		{"GET", "/invalid", 500, "Internal Server Error\n"},
+		// internal error details are not sent to clients
+		{"GET", "/secret", 500, "Internal Server Error\n"},
+		{"POST", "/registered", 403, "Forbidden\n"},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, newRequest(c.method, c.url))
+		if w.Code != c.code || w.Body.String() != c.body {
+			t.Errorf("%s %s -> %d %q, want %d %q", c.method, c.url, w.Code, w.Body.String(), c.code, c.body)
+		}
+	}
+}
+
+func TestHandlerFuncProblem(t *testing.T) {
+	// problem details are the default
+	mux := NewServeMux()
+	mux.GetE("/notes/:id", errorHandler(HTTPError{Code: http.StatusUnprocessableEntity, Msg: "id must be a number"}))
+	mux.GetE("/internal", errorHandler(errors.New("internal")))
+
+	cases := []struct {
+		url     string
+		problem Problem
+	}{
+		{"/notes/x", Problem{Type: "about:blank", Title: "Unprocessable Entity", Status: 422, Detail: "id must be a number", Instance: "/notes/x"}},
+		{"/internal", Problem{Type: "about:blank", Title: "Internal Server Error", Status: 500, Instance: "/internal"}},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, newRequest("GET", c.url))
+		var problem Problem
+		if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+			t.Fatalf("GET %s -> %q: %v", c.url, w.Body.String(), err)
+		}
+		if w.Code != c.problem.Status || w.Header().Get("Content-Type") != "application/problem+json" || !reflect.DeepEqual(problem, c.problem) {
+			t.Errorf("GET %s -> %d %+v, want %+v", c.url, w.Code, problem, c.problem)
+		}
+	}
+}
+
+func TestErrorStatus(t *testing.T) {
+	cases := []struct {
+		err    error
+		status int
+	}{
+		{HTTPError{Code: 409}, 409},
+		{fmt.Errorf("wrapped: %w", HTTPError{Code: 401}), 401},
+		{&HTTPError{Code: 410}, 410},
+		{ErrNotFound, 404},
+		{fs.ErrNotExist, 404},
+		{fs.ErrPermission, 403},
+		{errors.New("other"), 500},
+		// invalid codes would panic in WriteHeader
+		{HTTPError{Code: 42}, 500},
+		{HTTPError{Code: 1000, Msg: "too big"}, 500},
+		{HTTPError{Code: 103}, 500},
+		{HTTPError{}, 500},
+		// panics are bugs, whatever their value
+		{&PanicError{Value: HTTPError{Code: 400}}, 500},
+	}
+	for _, c := range cases {
+		if status := ErrorStatus(c.err); status != c.status {
+			t.Errorf("ErrorStatus(%v) = %d, want %d", c.err, status, c.status)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package warp
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// splitPattern splits a pattern into its host and path parts. Generic
+// patterns (beginning with '/') have an empty host part.
+func splitPattern(pattern string) (host, path string) {
+	if len(pattern) == 0 || pattern[0] == '/' {
+		return "", pattern
+	}
+	if i := strings.Index(pattern, "/"); i >= 0 {
+		return pattern[:i], pattern[i:]
+	}
+	return pattern, ""
+}
+
+// hostMatch returns whether the normalized request host matches the
+// normalized host pattern, how many runes matched, and the map of parameters
+// captured from the host. Hosts are compared label by label:
+//
+//	example.com          matches the host example.com only
+//	{tenant}.example.com matches acme.example.com, capturing :tenant=acme
+//	:tenant.example.com  is equivalent to {tenant}.example.com
+//	*.example.com        matches any single label subdomain of example.com
+//	example.com:8080     matches the host example.com on port 8080 only
+//
+// Capture and wildcard labels match exactly one non-empty label. The
+// request host's port is ignored unless the pattern specifies a port.
+func hostMatch(pattern, host string) (bool, int, url.Values) {
+	var params = make(url.Values)
+	var runeCount = 0
+
+	patternName, patternPort := splitHostPort(pattern)
+	hostName, hostPort := splitHostPort(host)
+	if patternPort != "" {
+		if patternPort != hostPort {
+			return false, runeCount, nil
+		}
+		runeCount += len(patternPort) + 1
+	}
+
+	patternLabels := strings.Split(patternName, ".")
+	hostLabels := strings.Split(hostName, ".")
+	if len(patternLabels) != len(hostLabels) {
+		return false, runeCount, nil
+	}
+	// dots between labels match literally
+	runeCount += len(patternLabels) - 1
+	for i, label := range patternLabels {
+		value := hostLabels[i]
+		switch {
+		case label == "*":
+			if value == "" {
+				return false, runeCount, nil
+			}
+		case isHostCapture(label):
+			if value == "" {
+				return false, runeCount, nil
+			}
+			params.Add(":"+hostCaptureName(label), value)
+		case label == value:
+			runeCount += utf8.RuneCountInString(label)
+		default:
+			return false, runeCount, nil
+		}
+	}
+	return true, runeCount, params
+}
+
+// normalizeHost returns the canonical form of a request host, which is
+// lowercased, stripped of any trailing dot, and converted to its ASCII
+// (punycode) form. The port, if any, is kept.
+func normalizeHost(host string) string {
+	name, port := splitHostPort(host)
+	name = toASCII(strings.TrimSuffix(strings.ToLower(name), "."))
+	if port != "" {
+		return name + ":" + port
+	}
+	return name
+}
+
+// normalizeHostPattern returns the canonical form of a host pattern. Literal
+// labels are normalized as by normalizeHost, while capture and wildcard labels
+// are kept as registered.
+func normalizeHostPattern(pattern string) string {
+	name, port := splitHostPort(pattern)
+	labels := strings.Split(strings.TrimSuffix(name, "."), ".")
+	for i, label := range labels {
+		if label != "*" && !isHostCapture(label) {
+			labels[i] = toASCII(strings.ToLower(label))
+		}
+	}
+	name = strings.Join(labels, ".")
+	if port != "" {
+		return name + ":" + port
+	}
+	return name
+}
+
+// requestHost returns the normalized request host, with the default port of
+// the request scheme added if the Host has no explicit port, so that patterns
+// such as example.com:443 match requests without one.
+func requestHost(request *http.Request) string {
+	if request.Host == "" {
+		return ""
+	}
+	host := normalizeHost(request.Host)
+	if _, port := splitHostPort(host); port == "" {
+		if request.TLS != nil {
+			return host + ":443"
+		}
+		return host + ":80"
+	}
+	return host
+}
+
+// isHostCapture returns true if the host pattern label captures the request
+// host label as a param, i.e. it has the form {name} or :name.
+func isHostCapture(label string) bool {
+	n := len(label)
+	switch {
+	case n > 2 && label[0] == '{' && label[n-1] == '}':
+		return true
+	case n > 1 && label[0] == ':':
+		return true
+	default:
+		return false
+	}
+}
+
+// hostCaptureName returns the param name of a {name} or :name host label.
+func hostCaptureName(label string) string {
+	if label[0] == ':' {
+		return label[1:]
+	}
+	return label[1 : len(label)-1]
+}
+
+// splitHostPort splits a host into its host name and port, which is empty if
+// the host has no port. Unlike net.SplitHostPort, a missing port is not an
+// error and only a numeric suffix after the last ':' is treated as a port, so
+// :name host capture labels and IPv6 literals are kept on the host name.
+func splitHostPort(host string) (string, string) {
+	i := strings.LastIndex(host, ":")
+	if i < 1 || !isPort(host[i+1:]) {
+		return host, ""
+	}
+	if net.ParseIP(host) != nil {
+		// unbracketed IPv6 literal without a port
+		return host, ""
+	}
+	return host[:i], host[i+1:]
+}
+
+// isPort returns true if the string is a non-empty decimal port number.
+func isPort(port string) bool {
+	if port == "" {
+		return false
+	}
+	for _, r := range port {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
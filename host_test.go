@@ -0,0 +1,157 @@
+package warp
+
+import (
+	"crypto/tls"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+// test host patterns
+
+var hostMatchTests = []struct {
+	pattern   string
+	host      string     // request host to be matched
+	isMatch   bool       // should the host match the pattern
+	runeCount int        // expected number of matching, non-param runes
+	params    url.Values // expected captured params key to value map
+}{
+	{"example.com", "example.com", true, 11, emptyParams},
+	{"example.com", "other.com", false, 1, nil},
+	// ports are ignored by default
+	{"example.com", "example.com:8080", true, 11, emptyParams},
+	// explicit ports must match
+	{"example.com:8080", "example.com:8080", true, 16, emptyParams},
+	{"example.com:8080", "example.com:9090", false, 0, nil},
+	{"example.com:8080", "example.com", false, 0, nil},
+	// capture labels
+	{"{tenant}.example.com", "acme.example.com", true, 12, url.Values{":tenant": {"acme"}}},
+	{"{tenant}.example.com", "acme.example.com:8080", true, 12, url.Values{":tenant": {"acme"}}},
+	{":tenant.example.com", "acme.example.com", true, 12, url.Values{":tenant": {"acme"}}},
+	{"{tenant}.example.com", "example.com", false, 0, nil},
+	{"{tenant}.example.com", "a.b.example.com", false, 0, nil},
+	{"{tenant}.{region}.example.com", "acme.eu.example.com", true, 13, url.Values{":tenant": {"acme"}, ":region": {"eu"}}},
+	// wildcard labels
+	{"*.example.com", "www.example.com", true, 12, emptyParams},
+	{"*.example.com", "example.com", false, 0, nil},
+	{"*.example.com", ".example.com", false, 2, nil},
+	// ip literals
+	{"127.0.0.1", "127.0.0.1:8080", true, 9, emptyParams},
+	{"[::1]", "[::1]:8080", true, 5, emptyParams},
+}
+
+var normalizeHostTests = []struct {
+	host       string
+	normalized string
+}{
+	{"example.com", "example.com"},
+	{"Example.COM:8080", "example.com:8080"},
+	{"example.com.", "example.com"},
+	{"bücher.example", "xn--bcher-kva.example"},
+	{"MÜNCHEN.de:443", "xn--mnchen-3ya.de:443"},
+	{"[::1]:8080", "[::1]:8080"},
+}
+
+func TestNormalizeHost(t *testing.T) {
+	for _, nt := range normalizeHostTests {
+		if normalized := normalizeHost(nt.host); normalized != nt.normalized {
+			t.Errorf("normalizeHost(%q) = %q, want %q", nt.host, normalized, nt.normalized)
+		}
+	}
+}
+
+func TestNormalizeHostPattern(t *testing.T) {
+	pattern := normalizeHostPattern("{Tenant}.*.Bücher.Example.:8080")
+	if want := "{Tenant}.*.xn--bcher-kva.example:8080"; pattern != want {
+		t.Errorf("normalizeHostPattern -> %q, want %q", pattern, want)
+	}
+}
+
+func TestHostMatch(t *testing.T) {
+	for _, ht := range hostMatchTests {
+		isMatch, runeCount, params := hostMatch(ht.pattern, ht.host)
+		if isMatch != ht.isMatch {
+			t.Errorf("host %s match pattern %s, %t, want %t", ht.host, ht.pattern, isMatch, ht.isMatch)
+		}
+		if runeCount != ht.runeCount {
+			t.Errorf("host %s match pattern %s, runeCount %d, want %d", ht.host, ht.pattern, runeCount, ht.runeCount)
+		}
+		if !reflect.DeepEqual(params, ht.params) {
+			t.Errorf("host %s match pattern %s, params %v, want %v", ht.host, ht.pattern, params, ht.params)
+		}
+	}
+}
+
+var registerHostRoutes = []string{
+	"/",
+	"example.com/",
+	"www.example.com/",
+	"{tenant}.example.com/",
+	"{tenant}.example.com/notes/:id",
+	"*.example.org/",
+	"Bücher.example/",
+	"secure.example.com:443/",
+	"secure.example.com:8443/",
+}
+
+var hostTests = []struct {
+	url     string     // test request url
+	pattern string     // expected pattern match
+	params  url.Values // expected captured params key to value map
+}{
+	{"http://example.com/", "example.com/", emptyParams},
+	{"http://EXAMPLE.com:8080/", "example.com/", emptyParams},
+	// literal labels are preferred over captures
+	{"http://www.example.com/", "www.example.com/", emptyParams},
+	{"http://acme.example.com/", "{tenant}.example.com/", url.Values{":tenant": {"acme"}}},
+	// captured host labels are lowercased
+	{"http://Acme.Example.com:8443/notes/7", "{tenant}.example.com/notes/:id", url.Values{":tenant": {"acme"}, ":id": {"7"}}},
+	{"http://shop.example.org/", "*.example.org/", emptyParams},
+	{"http://example.org/", "/", emptyParams},
+	// hosts are normalized to lowercase punycode
+	{"http://xn--bcher-kva.example/", "Bücher.example/", emptyParams},
+	{"http://BÜCHER.example./", "Bücher.example/", emptyParams},
+	// explicit port patterns, requests without a port use the default port
+	{"https://secure.example.com/", "secure.example.com:443/", emptyParams},
+	{"https://secure.example.com:443/", "secure.example.com:443/", emptyParams},
+	{"https://secure.example.com:8443/", "secure.example.com:8443/", emptyParams},
+	{"http://secure.example.com/", "{tenant}.example.com/", url.Values{":tenant": {"secure"}}},
+}
+
+func TestServeHTTPHosts(t *testing.T) {
+	mux := NewServeMux()
+	for _, pattern := range registerHostRoutes {
+		mux.Handle(pattern, stringHandler(pattern))
+	}
+
+	for _, ht := range hostTests {
+		r := newRequest("GET", ht.url)
+		if r.URL.Scheme == "https" {
+			r.TLS = &tls.ConnectionState{}
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if pattern := w.Header().Get("Result"); pattern != ht.pattern {
+			t.Errorf("GET %s -> pattern %s, want %s", ht.url, pattern, ht.pattern)
+		}
+		if !reflect.DeepEqual(r.URL.Query(), ht.params) {
+			t.Errorf("GET %s -> params %v, want %v", ht.url, r.URL.Query(), ht.params)
+		}
+	}
+}
+
+func TestHostTreeRedirectKeepsQuery(t *testing.T) {
+	mux := NewServeMux()
+	mux.Handle("{tenant}.example.com/docs/", stringHandler("docs"))
+
+	r := newRequest("GET", "https://acme.example.com/docs?page=2")
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	if w.Code != 301 {
+		t.Errorf("GET %s -> code %d, want 301", r.URL, w.Code)
+	}
+	if location, want := w.Header().Get("Location"), "/docs/?page=2"; location != want {
+		t.Errorf("GET %s -> location %q, want %q", r.URL, location, want)
+	}
+}
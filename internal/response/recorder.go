@@ -0,0 +1,84 @@
+// Package response provides the ResponseWriter wrapper shared by warp and
+// its middleware packages.
+package response
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+)
+
+// Recorder is a ResponseWriter which records the status and body size of the
+// response written to the underlying ResponseWriter, and whether its header
+// has been written.
+type Recorder struct {
+	http.ResponseWriter
+	Code        int   // final status written, 0 if none
+	Size        int64 // num body bytes written
+	WroteHeader bool  // whether the final header has been written
+}
+
+// NewRecorder returns a Recorder writing to w.
+func NewRecorder(w http.ResponseWriter) *Recorder {
+	return &Recorder{ResponseWriter: w}
+}
+
+// WriteHeader records the status and writes it to the underlying
+// ResponseWriter. 1xx informational responses precede the final header, so
+// are not recorded.
+func (w *Recorder) WriteHeader(code int) {
+	if code >= 200 && !w.WroteHeader {
+		w.Code = code
+		w.WroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write writes b to the underlying ResponseWriter, recording an implicit
+// 200 OK status if no header was written.
+func (w *Recorder) Write(b []byte) (int, error) {
+	w.writeImplicitHeader()
+	n, err := w.ResponseWriter.Write(b)
+	w.Size += int64(n)
+	return n, err
+}
+
+// Status returns the response status, 200 OK if the handler wrote none.
+func (w *Recorder) Status() int {
+	if w.Code == 0 {
+		return http.StatusOK
+	}
+	return w.Code
+}
+
+// Flush flushes the underlying ResponseWriter, if it supports flushing.
+func (w *Recorder) Flush() {
+	w.writeImplicitHeader()
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack hijacks the underlying ResponseWriter's connection, if it supports
+// hijacking.
+func (w *Recorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, ok := w.ResponseWriter.(http.Hijacker); ok {
+		w.WroteHeader = true
+		return hijacker.Hijack()
+	}
+	return nil, nil, http.ErrNotSupported
+}
+
+// Unwrap returns the underlying ResponseWriter, for http.ResponseController.
+func (w *Recorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// writeImplicitHeader records the 200 OK status net/http writes before the
+// body if the handler wrote no header.
+func (w *Recorder) writeImplicitHeader() {
+	if !w.WroteHeader {
+		w.Code = http.StatusOK
+		w.WroteHeader = true
+	}
+}
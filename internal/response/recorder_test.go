@@ -0,0 +1,43 @@
+package response
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRecorder(t *testing.T) {
+	cases := []struct {
+		name        string
+		write       func(w http.ResponseWriter)
+		status      int
+		size        int64
+		wroteHeader bool
+	}{
+		{"none", func(w http.ResponseWriter) {}, http.StatusOK, 0, false},
+		{"implicit", func(w http.ResponseWriter) { w.Write([]byte("hello")) }, http.StatusOK, 5, true},
+		{"header", func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusNotFound)
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("gone"))
+		}, http.StatusNotFound, 4, true},
+		{"informational", func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusEarlyHints)
+		}, http.StatusOK, 0, false},
+		{"flush", func(w http.ResponseWriter) { w.(http.Flusher).Flush() }, http.StatusOK, 0, true},
+	}
+	for _, c := range cases {
+		recorder := NewRecorder(httptest.NewRecorder())
+		c.write(recorder)
+		if recorder.Status() != c.status || recorder.Size != c.size || recorder.WroteHeader != c.wroteHeader {
+			t.Errorf("%s: Status() %d, Size %d, WroteHeader %v, want %d, %d, %v", c.name, recorder.Status(), recorder.Size, recorder.WroteHeader, c.status, c.size, c.wroteHeader)
+		}
+	}
+}
+
+func TestRecorderHijack(t *testing.T) {
+	recorder := NewRecorder(httptest.NewRecorder())
+	if _, _, err := recorder.Hijack(); err != http.ErrNotSupported {
+		t.Errorf("Hijack() error %v, want %v", err, http.ErrNotSupported)
+	}
+}
@@ -0,0 +1,113 @@
+package warp
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+)
+
+// Meta sets the metadata value for the key (e.g. "owner", "scopes") on the
+// Route, replacing any previous value. Metadata does not affect matching,
+// but lets middleware and introspection read declarative configuration from
+// the matched route:
+//
+//	mux.Get("/reports", reportsHandler).Meta("scopes", []string{"reports:read"})
+func (route *Route) Meta(key string, value interface{}) *Route {
+	if route.meta == nil {
+		route.meta = make(map[string]interface{})
+	}
+	route.meta[key] = value
+	return route
+}
+
+// Value returns the metadata value set for the key with Meta, or nil.
+func (route *Route) Value(key string) interface{} {
+	return route.meta[key]
+}
+
+// Tags adds the tags (e.g. "admin", "internal") to the Route. Duplicate tags
+// are ignored.
+func (route *Route) Tags(tags ...string) *Route {
+	for _, tag := range tags {
+		if !contains(route.tags, tag) {
+			route.tags = append(route.tags, tag)
+		}
+	}
+	return route
+}
+
+// HasTag returns true if the Route has the tag.
+func (route *Route) HasTag(tag string) bool {
+	return contains(route.tags, tag)
+}
+
+// TagList returns a copy of the Route tags, in the order they were added.
+func (route *Route) TagList() []string {
+	return append([]string(nil), route.tags...)
+}
+
+// Pattern returns the pattern the Route was registered with.
+func (route *Route) Pattern() string {
+	return route.pattern
+}
+
+// Routes returns the registered routes, sorted by pattern and then in
+// registration order.
+func (mux *ServeMux) Routes() []*Route {
+	mux.mu.RLock()
+	defer mux.mu.RUnlock()
+	keys := make([]string, 0, len(mux.routes))
+	for key := range mux.routes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var routes []*Route
+	for _, key := range keys {
+		routes = append(routes, mux.routes[key]...)
+	}
+	return routes
+}
+
+// RequestRoute returns the Route whose handler ServeHTTP dispatched the
+// request to, or nil if the request was not dispatched to a Route (e.g. it
+// was redirected or rejected).
+func RequestRoute(request *http.Request) *Route {
+	if d := requestDispatch(request); d != nil {
+		return d.route
+	}
+	return nil
+}
+
+// RequestPattern returns the pattern of the route ServeHTTP matched the
+// request to, including requests it redirected, or "" if no route matched.
+func RequestPattern(request *http.Request) string {
+	if d := requestDispatch(request); d != nil {
+		return d.pattern
+	}
+	return ""
+}
+
+// RequestParams returns the params ServeHTTP captured from the request host
+// and path, keyed by their ':' prefixed names, or nil if none were captured.
+func RequestParams(request *http.Request) url.Values {
+	if d := requestDispatch(request); d != nil {
+		return d.params
+	}
+	return nil
+}
+
+// RequestRedirect returns true if ServeHTTP dispatched the request to a
+// redirect ServeMux generated, to a clean path, a trailing slash twin or a
+// canonical path, rather than to a handler.
+func RequestRedirect(request *http.Request) bool {
+	if d := requestDispatch(request); d != nil {
+		return d.redirect
+	}
+	return false
+}
+
+// requestDispatch returns the dispatch of the request by ServeHTTP, or nil.
+func requestDispatch(request *http.Request) *dispatch {
+	d, _ := request.Context().Value(dispatchKey).(*dispatch)
+	return d
+}
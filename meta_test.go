@@ -0,0 +1,103 @@
+package warp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestRouteMeta(t *testing.T) {
+	route := NewRoute("/reports", stringHandler("reports")).
+		Meta("owner", "billing").
+		Meta("tier", 2).
+		Meta("owner", "finance").
+		Tags("admin", "internal", "admin")
+
+	if owner := route.Value("owner"); owner != "finance" {
+		t.Errorf("Value(owner) = %v, want finance", owner)
+	}
+	if tier := route.Value("tier"); tier != 2 {
+		t.Errorf("Value(tier) = %v, want 2", tier)
+	}
+	if missing := route.Value("missing"); missing != nil {
+		t.Errorf("Value(missing) = %v, want nil", missing)
+	}
+	if tags := route.TagList(); !reflect.DeepEqual(tags, []string{"admin", "internal"}) {
+		t.Errorf("TagList() = %v, want [admin internal]", tags)
+	}
+	if !route.HasTag("internal") || route.HasTag("public") {
+		t.Errorf("HasTag reported wrong tags for %v", route.TagList())
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	mux := NewServeMux()
+	b := mux.Get("/b", stringHandler("b"))
+	a1 := mux.Get("/a", stringHandler("a1"))
+	a2 := mux.Post("/a", stringHandler("a2"))
+	host := mux.Get("Example.com/", stringHandler("host"))
+
+	want := []*Route{a1, a2, b, host}
+	if routes := mux.Routes(); !reflect.DeepEqual(routes, want) {
+		t.Errorf("Routes() = %v, want %v", routes, want)
+	}
+}
+
+func TestRequestRoute(t *testing.T) {
+	mux := NewServeMux()
+	var matched *Route
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		matched = RequestRoute(r)
+	})
+	route := mux.Get("/admin/:page", handler).Meta("scopes", []string{"admin"})
+	mux.Get("/tree/", handler)
+
+	matched = nil
+	mux.ServeHTTP(httptest.NewRecorder(), newRequest("GET", "/admin/users"))
+	if matched != route {
+		t.Errorf("RequestRoute() = %v, want %v", matched, route)
+	}
+	if scopes := matched.Value("scopes"); !reflect.DeepEqual(scopes, []string{"admin"}) {
+		t.Errorf("Value(scopes) = %v, want [admin]", scopes)
+	}
+	// redirects are not dispatched to the route
+	matched = nil
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, newRequest("GET", "/tree"))
+	if w.Code != http.StatusMovedPermanently || matched != nil {
+		t.Errorf("GET /tree -> %d, RequestRoute() %v, want 301 and nil", w.Code, matched)
+	}
+}
+
+func TestRequestParamsAndRedirect(t *testing.T) {
+	mux := NewServeMux()
+	var pattern string
+	var params url.Values
+	var redirect bool
+	mux.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			pattern, params, redirect = RequestPattern(r), RequestParams(r), RequestRedirect(r)
+			next.ServeHTTP(w, r)
+		})
+	})
+	mux.Get("/notes/:id/", stringHandler("note"))
+
+	cases := []struct {
+		url      string
+		pattern  string
+		params   url.Values
+		redirect bool
+	}{
+		{"/notes/1/", "/notes/:id/", url.Values{":id": {"1"}}, false},
+		{"/notes/1", "/notes/:id/", url.Values{":id": {"1"}}, true},
+		{"/missing", "", nil, false},
+	}
+	for _, c := range cases {
+		mux.ServeHTTP(httptest.NewRecorder(), newRequest("GET", c.url))
+		if pattern != c.pattern || !reflect.DeepEqual(params, c.params) || redirect != c.redirect {
+			t.Errorf("GET %s -> RequestPattern() %q, RequestParams() %v, RequestRedirect() %v, want %q, %v, %v", c.url, pattern, params, redirect, c.pattern, c.params, c.redirect)
+		}
+	}
+}
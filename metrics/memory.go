@@ -0,0 +1,181 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets are the default upper bounds of latency histogram buckets,
+// in seconds, as used by Prometheus clients.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Series is a snapshot of the metrics of requests with the same Labels.
+type Series struct {
+	Count        int64         // num requests
+	Latency      time.Duration // total latency
+	Buckets      []int64       // cumulative latency counts per bucket
+	ResponseSize int64         // total response body bytes
+}
+
+// Memory is a Collector which keeps metrics in memory, for tests and for
+// serving them in the Prometheus text format. The zero value is not usable,
+// use NewMemory.
+type Memory struct {
+	buckets  []float64 // latency bucket upper bounds, in seconds
+	mu       sync.Mutex
+	series   map[Labels]*Series
+	inFlight map[[2]string]int // pattern and method -> requests
+}
+
+// NewMemory returns a new Memory collector with latency histogram buckets
+// with the given upper bounds in seconds, or DefaultBuckets if none.
+func NewMemory(buckets ...float64) *Memory {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	return &Memory{
+		buckets:  buckets,
+		series:   make(map[Labels]*Series),
+		inFlight: make(map[[2]string]int),
+	}
+}
+
+// AddInFlight adds delta to the in-flight requests for the pattern and
+// method.
+func (m *Memory) AddInFlight(pattern, method string, delta int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := [2]string{pattern, method}
+	m.inFlight[key] += delta
+	if m.inFlight[key] == 0 {
+		delete(m.inFlight, key)
+	}
+}
+
+// ObserveRequest records a completed request.
+func (m *Memory) ObserveRequest(labels Labels, latency time.Duration, responseSize int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	series := m.series[labels]
+	if series == nil {
+		series = &Series{Buckets: make([]int64, len(m.buckets))}
+		m.series[labels] = series
+	}
+	series.Count++
+	series.Latency += latency
+	series.ResponseSize += responseSize
+	for i, bound := range m.buckets {
+		if latency.Seconds() <= bound {
+			series.Buckets[i]++
+		}
+	}
+}
+
+// InFlight returns the number of in-flight requests for the pattern and
+// method.
+func (m *Memory) InFlight(pattern, method string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.inFlight[[2]string{pattern, method}]
+}
+
+// Series returns a snapshot of the metrics of requests with the labels.
+func (m *Memory) Series(labels Labels) Series {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	series := m.series[labels]
+	if series == nil {
+		return Series{Buckets: make([]int64, len(m.buckets))}
+	}
+	snapshot := *series
+	snapshot.Buckets = append([]int64(nil), series.Buckets...)
+	return snapshot
+}
+
+// WriteTo writes the metrics in the Prometheus text exposition format.
+func (m *Memory) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p := &printer{w: w}
+
+	labels := make([]Labels, 0, len(m.series))
+	for l := range m.series {
+		labels = append(labels, l)
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		a, b := labels[i], labels[j]
+		if a.Pattern != b.Pattern {
+			return a.Pattern < b.Pattern
+		}
+		if a.Method != b.Method {
+			return a.Method < b.Method
+		}
+		return a.Status < b.Status
+	})
+
+	p.printf("# TYPE http_requests_total counter\n")
+	for _, l := range labels {
+		p.printf("http_requests_total{%s} %d\n", l, m.series[l].Count)
+	}
+	p.printf("# TYPE http_request_duration_seconds histogram\n")
+	for _, l := range labels {
+		series := m.series[l]
+		for i, bound := range m.buckets {
+			p.printf("http_request_duration_seconds_bucket{%s,le=%q} %d\n", l, strconv.FormatFloat(bound, 'g', -1, 64), series.Buckets[i])
+		}
+		p.printf("http_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", l, series.Count)
+		p.printf("http_request_duration_seconds_sum{%s} %g\n", l, series.Latency.Seconds())
+		p.printf("http_request_duration_seconds_count{%s} %d\n", l, series.Count)
+	}
+	p.printf("# TYPE http_response_size_bytes_total counter\n")
+	for _, l := range labels {
+		p.printf("http_response_size_bytes_total{%s} %d\n", l, m.series[l].ResponseSize)
+	}
+
+	keys := make([][2]string, 0, len(m.inFlight))
+	for key := range m.inFlight {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i][0] < keys[j][0] || keys[i][0] == keys[j][0] && keys[i][1] < keys[j][1]
+	})
+	p.printf("# TYPE http_requests_in_flight gauge\n")
+	for _, key := range keys {
+		p.printf("http_requests_in_flight{pattern=%q,method=%q} %d\n", key[0], key[1], m.inFlight[key])
+	}
+	return p.n, p.err
+}
+
+// ServeHTTP serves the metrics in the Prometheus text exposition format.
+func (m *Memory) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.WriteTo(w)
+}
+
+// String returns the labels in the Prometheus text format.
+func (l Labels) String() string {
+	return fmt.Sprintf("pattern=%q,method=%q,status=\"%d\"", l.Pattern, l.Method, l.Status)
+}
+
+// printer writes formatted lines, keeping the first error.
+type printer struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (p *printer) printf(format string, args ...interface{}) {
+	if p.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(p.w, format, args...)
+	p.n += int64(n)
+	p.err = err
+}
@@ -0,0 +1,83 @@
+// Package metrics provides per-route request metrics middleware for a
+// warp.ServeMux. Metrics are labelled by the matched route pattern, rather
+// than the raw request path, so their cardinality is bounded by the number of
+// routes. Requests which no route handled, such as redirects and 404s, have
+// an empty pattern, and requests with non-standard methods are labelled with
+// the method Other.
+//
+// Metrics are recorded by a Collector, which may adapt a metrics library such
+// as Prometheus, or the in-memory Memory collector:
+//
+//	collector := metrics.NewMemory()
+//	mux.Use(metrics.Middleware(collector))
+//	mux.Get("/metrics", collector)
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/dghubble/warp"
+	"github.com/dghubble/warp/internal/response"
+)
+
+// Other labels requests with methods other than the standard HTTP methods,
+// so clients cannot grow the number of series with arbitrary methods.
+const Other = "OTHER"
+
+// standardMethods are the HTTP methods labelled as themselves.
+var standardMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodConnect: true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
+// Labels identify the requests a metric describes.
+type Labels struct {
+	Pattern string // matched route pattern, empty if no route matched
+	Method  string // standard request method, or Other
+	Status  int
+}
+
+// Collector records request metrics.
+type Collector interface {
+	// AddInFlight adds delta to the number of in-flight requests for the
+	// pattern and method, a gauge.
+	AddInFlight(pattern, method string, delta int)
+	// ObserveRequest records a completed request, counting it and observing
+	// its latency and response body size.
+	ObserveRequest(labels Labels, latency time.Duration, responseSize int64)
+}
+
+// Middleware returns warp.Middleware which records the metrics of each
+// request dispatched by a mux with the collector:
+//
+//	mux.Use(metrics.Middleware(collector))
+func Middleware(collector Collector) warp.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var pattern string
+			if route := warp.RequestRoute(r); route != nil {
+				pattern = route.Pattern()
+			}
+			method := r.Method
+			if !standardMethods[method] {
+				method = Other
+			}
+			collector.AddInFlight(pattern, method, 1)
+			defer collector.AddInFlight(pattern, method, -1)
+
+			start := time.Now()
+			recorder := response.NewRecorder(w)
+			next.ServeHTTP(recorder, r)
+			labels := Labels{Pattern: pattern, Method: method, Status: recorder.Status()}
+			collector.ObserveRequest(labels, time.Since(start), recorder.Size)
+		})
+	}
+}
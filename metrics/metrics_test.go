@@ -0,0 +1,101 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dghubble/warp"
+)
+
+func TestMiddleware(t *testing.T) {
+	collector := NewMemory()
+	mux := warp.NewServeMux()
+	mux.Use(Middleware(collector))
+	mux.Get("/notes/:id", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if inFlight := collector.InFlight("/notes/:id", "GET"); inFlight != 1 {
+			t.Errorf("InFlight() during request = %d, want 1", inFlight)
+		}
+		w.Write([]byte("note"))
+	}))
+	mux.Post("/notes", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	for _, url := range []string{"/notes/1", "/notes/2", "/missing"} {
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", url, nil))
+	}
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/notes", nil))
+	for _, method := range []string{"PURGE", "get", "X-RANDOM-1"} {
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/notes", nil))
+	}
+
+	cases := []struct {
+		labels Labels
+		count  int64
+		size   int64
+	}{
+		// labelled by pattern, not path
+		{Labels{"/notes/:id", "GET", 200}, 2, 8},
+		{Labels{"/notes", "POST", 201}, 1, 0},
+		{Labels{"", "GET", 404}, 1, 19},
+		// non-standard methods share one label
+		{Labels{"", Other, 404}, 3, 57},
+	}
+	for _, c := range cases {
+		series := collector.Series(c.labels)
+		if series.Count != c.count || series.ResponseSize != c.size {
+			t.Errorf("Series(%v) = %d requests, %d bytes, want %d, %d", c.labels, series.Count, series.ResponseSize, c.count, c.size)
+		}
+		if last := series.Buckets[len(series.Buckets)-1]; last != c.count {
+			t.Errorf("Series(%v) last bucket %d, want %d", c.labels, last, c.count)
+		}
+	}
+	if inFlight := collector.InFlight("/notes/:id", "GET"); inFlight != 0 {
+		t.Errorf("InFlight() after requests = %d, want 0", inFlight)
+	}
+}
+
+func TestMemoryBuckets(t *testing.T) {
+	collector := NewMemory(1, 0.1)
+	labels := Labels{"/", "GET", 200}
+	collector.ObserveRequest(labels, 50*time.Millisecond, 0)
+	collector.ObserveRequest(labels, 500*time.Millisecond, 0)
+	collector.ObserveRequest(labels, 5*time.Second, 0)
+
+	series := collector.Series(labels)
+	if series.Buckets[0] != 1 || series.Buckets[1] != 2 || series.Count != 3 {
+		t.Errorf("Series() buckets %v, count %d, want [1 2], 3", series.Buckets, series.Count)
+	}
+	if series.Latency != 5550*time.Millisecond {
+		t.Errorf("Series() latency %v, want 5.55s", series.Latency)
+	}
+}
+
+func TestMemoryServeHTTP(t *testing.T) {
+	collector := NewMemory(0.5)
+	collector.ObserveRequest(Labels{"/notes/:id", "GET", 200}, 250*time.Millisecond, 4)
+	collector.AddInFlight("/notes", "POST", 1)
+
+	w := httptest.NewRecorder()
+	collector.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	expected := strings.Join([]string{
+		`# TYPE http_requests_total counter`,
+		`http_requests_total{pattern="/notes/:id",method="GET",status="200"} 1`,
+		`# TYPE http_request_duration_seconds histogram`,
+		`http_request_duration_seconds_bucket{pattern="/notes/:id",method="GET",status="200",le="0.5"} 1`,
+		`http_request_duration_seconds_bucket{pattern="/notes/:id",method="GET",status="200",le="+Inf"} 1`,
+		`http_request_duration_seconds_sum{pattern="/notes/:id",method="GET",status="200"} 0.25`,
+		`http_request_duration_seconds_count{pattern="/notes/:id",method="GET",status="200"} 1`,
+		`# TYPE http_response_size_bytes_total counter`,
+		`http_response_size_bytes_total{pattern="/notes/:id",method="GET",status="200"} 4`,
+		`# TYPE http_requests_in_flight gauge`,
+		`http_requests_in_flight{pattern="/notes",method="POST"} 1`,
+		``,
+	}, "\n")
+	if body := w.Body.String(); body != expected {
+		t.Errorf("ServeHTTP() body\n%s\nwant\n%s", body, expected)
+	}
+}
@@ -0,0 +1,106 @@
+package warp
+
+import (
+	"mime"
+	"strconv"
+	"strings"
+)
+
+// mediaRange is a parsed media range from an Accept header, such as
+// "text/*;q=0.5".
+type mediaRange struct {
+	typ     string  // type, possibly the wildcard "*"
+	subtype string  // subtype, possibly the wildcard "*"
+	params  int     // number of media type params, excluding q
+	quality float64 // quality value q, between 0 and 1
+}
+
+// parseAccept parses an Accept header value into its media ranges. Malformed
+// ranges are skipped and ranges without a q param have quality 1.
+func parseAccept(header string) []mediaRange {
+	var ranges []mediaRange
+	for _, part := range strings.Split(header, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		typ, subtype := splitMediaType(mediaType)
+		if typ == "" {
+			continue
+		}
+		mr := mediaRange{typ: typ, subtype: subtype, quality: 1}
+		for key, value := range params {
+			if key != "q" {
+				mr.params++
+				continue
+			}
+			q, err := strconv.ParseFloat(value, 64)
+			if err != nil || q < 0 {
+				q = 0
+			}
+			if q > 1 {
+				q = 1
+			}
+			mr.quality = q
+		}
+		ranges = append(ranges, mr)
+	}
+	return ranges
+}
+
+// specificity returns how specifically the media range matches the given
+// media type, or -1 if it does not match. Exact type/subtype matches are
+// more specific than type/* matches, which are more specific than */*.
+func (mr mediaRange) specificity(typ, subtype string) int {
+	switch {
+	case mr.typ == typ && mr.subtype == subtype:
+		return 2 + mr.params
+	case mr.typ == typ && mr.subtype == "*":
+		return 1
+	case mr.typ == "*" && mr.subtype == "*":
+		return 0
+	default:
+		return -1
+	}
+}
+
+// acceptQuality returns the quality the media ranges give to the media type,
+// which is the quality of the most specific matching range. An empty list of
+// ranges (no Accept header) accepts every media type with quality 1.
+func acceptQuality(ranges []mediaRange, mediaType string) float64 {
+	if len(ranges) == 0 {
+		return 1
+	}
+	typ, subtype := splitMediaType(mediaType)
+	var quality float64
+	var best = -1
+	for _, mr := range ranges {
+		if s := mr.specificity(typ, subtype); s > best {
+			best = s
+			quality = mr.quality
+		}
+	}
+	return quality
+}
+
+// mediaTypeMatch returns true if the media type matches the pattern, which
+// may use wildcards (e.g. "*/*" or "text/*").
+func mediaTypeMatch(pattern, mediaType string) bool {
+	ptyp, psubtype := splitMediaType(pattern)
+	typ, subtype := splitMediaType(mediaType)
+	if ptyp == "*" {
+		return psubtype == "*"
+	}
+	return ptyp == typ && (psubtype == "*" || psubtype == subtype)
+}
+
+// splitMediaType splits a lowercase "type/subtype" media type into its type
+// and subtype. Returns empty strings if the media type is malformed.
+func splitMediaType(mediaType string) (string, string) {
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	i := strings.Index(mediaType, "/")
+	if i < 1 || i == len(mediaType)-1 {
+		return "", ""
+	}
+	return mediaType[:i], mediaType[i+1:]
+}
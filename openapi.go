@@ -0,0 +1,387 @@
+package warp
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// OpenAPI is an OpenAPI 3 document describing the routes of a ServeMux.
+// See https://spec.openapis.org/oas/v3.0.3
+type OpenAPI struct {
+	OpenAPI string                      `json:"openapi"`
+	Info    OpenAPIInfo                 `json:"info"`
+	Servers []OpenAPIServer             `json:"servers,omitempty"`
+	Paths   map[string]*OpenAPIPathItem `json:"paths"`
+}
+
+// OpenAPIInfo is the metadata of an OpenAPI document.
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// OpenAPIPathItem describes the operations available on a path.
+type OpenAPIPathItem struct {
+	Servers []OpenAPIServer   `json:"servers,omitempty"`
+	Get     *OpenAPIOperation `json:"get,omitempty"`
+	Put     *OpenAPIOperation `json:"put,omitempty"`
+	Post    *OpenAPIOperation `json:"post,omitempty"`
+	Delete  *OpenAPIOperation `json:"delete,omitempty"`
+	Options *OpenAPIOperation `json:"options,omitempty"`
+	Head    *OpenAPIOperation `json:"head,omitempty"`
+	Patch   *OpenAPIOperation `json:"patch,omitempty"`
+	Trace   *OpenAPIOperation `json:"trace,omitempty"`
+}
+
+// OpenAPIServer is a server which serves a path, with variables for the
+// params captured from the host.
+type OpenAPIServer struct {
+	URL       string                           `json:"url"`
+	Variables map[string]OpenAPIServerVariable `json:"variables,omitempty"`
+}
+
+// OpenAPIServerVariable is a variable substituted in a server URL.
+type OpenAPIServerVariable struct {
+	Default string `json:"default"`
+}
+
+// OpenAPIOperation describes a single method on a path.
+type OpenAPIOperation struct {
+	OperationID string                     `json:"operationId,omitempty"`
+	Summary     string                     `json:"summary,omitempty"`
+	Description string                     `json:"description,omitempty"`
+	Tags        []string                   `json:"tags,omitempty"`
+	Parameters  []OpenAPIParameter         `json:"parameters,omitempty"`
+	Responses   map[string]OpenAPIResponse `json:"responses"`
+}
+
+// OpenAPIParameter describes a parameter of an operation.
+type OpenAPIParameter struct {
+	Name        string        `json:"name"`
+	In          string        `json:"in"`
+	Description string        `json:"description,omitempty"`
+	Required    bool          `json:"required"`
+	Schema      OpenAPISchema `json:"schema"`
+}
+
+// OpenAPISchema is the data type of a parameter.
+type OpenAPISchema struct {
+	Type string `json:"type"`
+}
+
+// OpenAPIResponse describes a response of an operation.
+type OpenAPIResponse struct {
+	Description string `json:"description"`
+}
+
+// openAPIMethods are the methods OpenAPI path items describe, in order.
+var openAPIMethods = []string{"GET", "PUT", "POST", "DELETE", "OPTIONS", "HEAD", "PATCH", "TRACE"}
+
+// OpenAPI returns an OpenAPI 3 document describing the registered routes.
+// Patterns are converted to templated paths, so "/users/:id" and
+// "/files/*path" become "/users/{id}" and "/files/{path}", with one path per
+// expansion of any optional groups. Captured params are documented as
+// required path parameters, numbered if a pattern repeats a name, as in
+// "/compare/{id}/{id2}". Patterns which differ only in param names, such
+// as "/users/:id" and "/users/:name", share the path and parameter names of
+// the first. Each method a Route is restricted to becomes an
+// operation, while Routes without method rules describe every method not
+// otherwise routed, and Routes which allow no methods are skipped. The
+// "summary", "description" and "operationId" string
+// metadata of a Route (see Route.Meta) and its Tags describe its
+// operations. When several routes describe the same path and method, the
+// first in Routes order is used.
+//
+// Routes with host patterns are documented with their host as the path's
+// server, with host captures as server variables. A path is documented for
+// routes of one host only, preferring routes without hosts, since servers
+// apply to every operation of a path. Use OpenAPIHost to document the
+// routes a particular host serves.
+func (mux *ServeMux) OpenAPI(info OpenAPIInfo) *OpenAPI {
+	builder := &openAPIBuilder{
+		doc:         newOpenAPI(info),
+		templates:   make(map[string]openAPITemplate),
+		owners:      make(map[string]string),
+		pathServers: true,
+	}
+	var generic, hosted []*Route
+	for _, route := range mux.Routes() {
+		if routeHost(route) == "" {
+			generic = append(generic, route)
+		} else {
+			hosted = append(hosted, route)
+		}
+	}
+	builder.addRoutes(generic)
+	builder.addRoutes(hosted)
+	return builder.doc
+}
+
+// OpenAPIHost returns an OpenAPI 3 document describing the routes serving
+// the host pattern, as registered (e.g. "api.example.com" or
+// "{tenant}.example.com"), with the host as the document's server. Routes
+// registered for the host take precedence over routes without hosts, as
+// when matching requests. Paths are documented as by OpenAPI.
+func (mux *ServeMux) OpenAPIHost(info OpenAPIInfo, host string) *OpenAPI {
+	builder := &openAPIBuilder{
+		doc:       newOpenAPI(info),
+		templates: make(map[string]openAPITemplate),
+	}
+	builder.doc.Servers = []OpenAPIServer{*openAPIServer(host)}
+	key := normalizeHostPattern(host)
+	var hosted, generic []*Route
+	for _, route := range mux.Routes() {
+		switch routeHost(route) {
+		case key:
+			hosted = append(hosted, route)
+		case "":
+			generic = append(generic, route)
+		}
+	}
+	builder.addRoutes(hosted)
+	builder.addRoutes(generic)
+	return builder.doc
+}
+
+// newOpenAPI returns an OpenAPI document with no paths.
+func newOpenAPI(info OpenAPIInfo) *OpenAPI {
+	return &OpenAPI{
+		OpenAPI: "3.0.3",
+		Info:    info,
+		Paths:   make(map[string]*OpenAPIPathItem),
+	}
+}
+
+// routeHost returns the normalized host of the route pattern, or an empty
+// string if the pattern has no host.
+func routeHost(route *Route) string {
+	host, _ := splitPattern(route.pattern)
+	if host == "" {
+		return ""
+	}
+	return normalizeHostPattern(host)
+}
+
+// openAPIBuilder adds routes to an OpenAPI document.
+type openAPIBuilder struct {
+	doc *OpenAPI
+	// templates maps the shapes of documented paths, with unnamed params, to
+	// the first path documented with the shape
+	templates map[string]openAPITemplate
+	// owners maps documented paths to the normalized host of their routes,
+	// if paths may only be documented for routes of one host
+	owners map[string]string
+	// whether to document the hosts of routes as path servers
+	pathServers bool
+}
+
+// openAPITemplate is a documented path and the names of its params.
+type openAPITemplate struct {
+	path  string
+	names []string
+}
+
+// addRoutes adds the routes to the document. Routes restricted to methods
+// take precedence over unrestricted routes.
+func (b *openAPIBuilder) addRoutes(routes []*Route) {
+	var unrestricted []*Route
+	for _, route := range routes {
+		methods := route.methods()
+		if methods == nil {
+			unrestricted = append(unrestricted, route)
+			continue
+		}
+		b.addRoute(route, methods)
+	}
+	for _, route := range unrestricted {
+		b.addRoute(route, openAPIMethods)
+	}
+}
+
+// addRoute adds operations for the methods of the route to the document,
+// unless the path already has an operation for a method, or is documented
+// for routes of another host. Paths are only added with an operation.
+func (b *openAPIBuilder) addRoute(route *Route, methods []string) {
+	host, pathPattern := splitPattern(route.pattern)
+	if pathPattern == "" {
+		pathPattern = "/"
+	}
+	for _, expanded := range expandOptional(pathPattern) {
+		path, shape, names := openAPIPath(expanded)
+		if template, ok := b.templates[shape]; ok {
+			path, names = template.path, template.names
+		}
+		if b.owners != nil {
+			if owner, ok := b.owners[path]; ok && owner != routeHost(route) {
+				continue
+			}
+		}
+		item := b.doc.Paths[path]
+		if item == nil {
+			item = new(OpenAPIPathItem)
+			if host != "" && b.pathServers {
+				item.Servers = []OpenAPIServer{*openAPIServer(host)}
+			}
+		}
+		var added bool
+		for _, method := range methods {
+			if operation := item.operation(method); operation != nil && *operation == nil {
+				*operation = newOpenAPIOperation(route, names)
+				added = true
+			}
+		}
+		if !added {
+			continue
+		}
+		b.doc.Paths[path] = item
+		b.templates[shape] = openAPITemplate{path, names}
+		if b.owners != nil {
+			b.owners[path] = routeHost(route)
+		}
+	}
+}
+
+// operation returns the field of the path item for the method, or nil if
+// OpenAPI does not describe the method.
+func (item *OpenAPIPathItem) operation(method string) **OpenAPIOperation {
+	switch method {
+	case "GET":
+		return &item.Get
+	case "PUT":
+		return &item.Put
+	case "POST":
+		return &item.Post
+	case "DELETE":
+		return &item.Delete
+	case "OPTIONS":
+		return &item.Options
+	case "HEAD":
+		return &item.Head
+	case "PATCH":
+		return &item.Patch
+	case "TRACE":
+		return &item.Trace
+	default:
+		return nil
+	}
+}
+
+// newOpenAPIOperation returns an operation describing the route, with the
+// named path parameters.
+func newOpenAPIOperation(route *Route, names []string) *OpenAPIOperation {
+	operation := &OpenAPIOperation{
+		OperationID: metaString(route, "operationId"),
+		Summary:     metaString(route, "summary"),
+		Description: metaString(route, "description"),
+		Tags:        route.TagList(),
+		Responses: map[string]OpenAPIResponse{
+			"default": {Description: "Response from " + route.pattern},
+		},
+	}
+	for _, name := range names {
+		operation.Parameters = append(operation.Parameters, OpenAPIParameter{
+			Name:     name,
+			In:       "path",
+			Required: true,
+			Schema:   OpenAPISchema{Type: "string"},
+		})
+	}
+	return operation
+}
+
+// openAPIPath returns the templated form of a path pattern, without optional
+// groups, its shape, with unnamed params as in "/users/{}", and the names of
+// its params in order of appearance.
+func openAPIPath(pattern string) (string, string, []string) {
+	var path, shape []rune
+	var names []string
+	rPattern := []rune(pattern)
+	for i := 0; i < len(rPattern); {
+		if name := splatName(rPattern, i); name != "" {
+			name = uniqueParamName(names, name)
+			path = append(path, []rune("{"+name+"}")...)
+			shape = append(shape, '{', '}')
+			names = append(names, name)
+			break
+		}
+		if isEscapedStar(rPattern, i) {
+			path = append(path, '*')
+			shape = append(shape, '*')
+			i += 2
+			continue
+		}
+		if rPattern[i] != ':' {
+			path = append(path, rPattern[i])
+			shape = append(shape, rPattern[i])
+			i++
+			continue
+		}
+		var name string
+		name, i, _ = captureName(rPattern, i+1)
+		name = uniqueParamName(names, name)
+		path = append(path, []rune("{"+name+"}")...)
+		shape = append(shape, '{', '}')
+		names = append(names, name)
+	}
+	return string(path), string(shape), names
+}
+
+// uniqueParamName returns the name, or if it is one of the names, the name
+// numbered from 2 so it is unique, as OpenAPI requires of path templates.
+func uniqueParamName(names []string, name string) string {
+	unique := name
+	for n := 2; contains(names, unique); n++ {
+		unique = name + strconv.Itoa(n)
+	}
+	return unique
+}
+
+// openAPIServer returns a scheme relative server for the host pattern, with
+// a variable for each host capture label, defaulting to the param name.
+func openAPIServer(host string) *OpenAPIServer {
+	server := new(OpenAPIServer)
+	name, port := splitHostPort(normalizeHostPattern(host))
+	labels := strings.Split(name, ".")
+	for i, label := range labels {
+		if isHostCapture(label) {
+			name := hostCaptureName(label)
+			labels[i] = "{" + name + "}"
+			if server.Variables == nil {
+				server.Variables = make(map[string]OpenAPIServerVariable)
+			}
+			server.Variables[name] = OpenAPIServerVariable{Default: name}
+		}
+	}
+	server.URL = "//" + strings.Join(labels, ".")
+	if port != "" {
+		server.URL += ":" + port
+	}
+	return server
+}
+
+// metaString returns the string metadata value of the route for the key, or
+// an empty string.
+func metaString(route *Route, key string) string {
+	value, _ := route.Value(key).(string)
+	return value
+}
+
+// OpenAPIHandler returns a handler which serves the OpenAPI document of the
+// ServeMux routes as JSON. The document is generated on each request, so it
+// describes routes registered after the handler was created.
+//
+//	mux.Get("/openapi.json", warp.OpenAPIHandler(mux, warp.OpenAPIInfo{Title: "Reports", Version: "1.0.0"}))
+func OpenAPIHandler(mux *ServeMux, info OpenAPIInfo) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := json.MarshalIndent(mux.OpenAPI(info), "", "  ")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	})
+}
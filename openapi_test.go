@@ -0,0 +1,169 @@
+package warp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+var openAPIPathTests = []struct {
+	pattern string
+	path    string
+	shape   string
+	names   []string
+}{
+	{"/users", "/users", "/users", nil},
+	{"/users/:id", "/users/{id}", "/users/{}", []string{"id"}},
+	{"/files/:name.:ext", "/files/{name}.{ext}", "/files/{}.{}", []string{"name", "ext"}},
+	// repeated names are numbered, so path templates are valid
+	{"/compare/:id/:id", "/compare/{id}/{id2}", "/compare/{}/{}", []string{"id", "id2"}},
+	{"/compare/:id/:id2/:id", "/compare/{id}/{id2}/{id3}", "/compare/{}/{}/{}", []string{"id", "id2", "id3"}},
+	{"/files/:path/*path", "/files/{path}/{path2}", "/files/{}/{}", []string{"path", "path2"}},
+	{"/tags/*tags", "/tags/{tags}", "/tags/{}", []string{"tags"}},
+	{"/static/", "/static/", "/static/", nil},
+}
+
+func TestOpenAPIPath(t *testing.T) {
+	for _, ot := range openAPIPathTests {
+		path, shape, names := openAPIPath(ot.pattern)
+		if path != ot.path || shape != ot.shape || !reflect.DeepEqual(names, ot.names) {
+			t.Errorf("openAPIPath(%q) = %q, %q, %v, want %q, %q, %v", ot.pattern, path, shape, names, ot.path, ot.shape, ot.names)
+		}
+	}
+}
+
+func TestOpenAPI(t *testing.T) {
+	mux := NewServeMux()
+	mux.Get("/users/:id", stringHandler("user")).
+		Meta("summary", "Get a user").
+		Meta("operationId", "getUser").
+		Tags("users")
+	mux.Register("/users/:id", stringHandler("user methods")).Methods("PUT", "DELETE")
+	mux.Handle("/users/:id", stringHandler("any"))
+	mux.Get("/reports/:year(/:month)", stringHandler("reports"))
+	mux.Post("{tenant}.example.com/upload", stringHandler("upload"))
+	doc := mux.OpenAPI(OpenAPIInfo{Title: "Test", Version: "1.0.0"})
+
+	if doc.OpenAPI != "3.0.3" || doc.Info.Title != "Test" {
+		t.Errorf("OpenAPI() = %s %v, want 3.0.3 {Test 1.0.0}", doc.OpenAPI, doc.Info)
+	}
+	users := doc.Paths["/users/{id}"]
+	if users == nil || users.Get == nil || users.Put == nil || users.Delete == nil {
+		t.Fatalf("OpenAPI() paths[/users/{id}] = %+v, want get, put and delete", users)
+	}
+	if users.Get.Summary != "Get a user" || users.Get.OperationID != "getUser" || !reflect.DeepEqual(users.Get.Tags, []string{"users"}) {
+		t.Errorf("get /users/{id} = %+v, want route metadata", users.Get)
+	}
+	wantParams := []OpenAPIParameter{{Name: "id", In: "path", Required: true, Schema: OpenAPISchema{Type: "string"}}}
+	if !reflect.DeepEqual(users.Get.Parameters, wantParams) {
+		t.Errorf("get /users/{id} parameters = %v, want %v", users.Get.Parameters, wantParams)
+	}
+	// unrestricted routes describe the remaining methods
+	if users.Post == nil || users.Post.Responses["default"].Description != "Response from /users/:id" || users.Put.Summary != "" {
+		t.Errorf("post /users/{id} = %+v, want unrestricted route", users.Post)
+	}
+	// optional groups expand to several paths
+	for _, path := range []string{"/reports/{year}", "/reports/{year}/{month}"} {
+		if item := doc.Paths[path]; item == nil || item.Get == nil {
+			t.Errorf("OpenAPI() paths[%s] = %v, want get", path, item)
+		}
+	}
+	upload := doc.Paths["/upload"]
+	wantServers := []OpenAPIServer{{
+		URL:       "//{tenant}.example.com",
+		Variables: map[string]OpenAPIServerVariable{"tenant": {Default: "tenant"}},
+	}}
+	if upload == nil || upload.Post == nil || !reflect.DeepEqual(upload.Servers, wantServers) {
+		t.Errorf("OpenAPI() paths[/upload] = %+v, want post with servers %v", upload, wantServers)
+	}
+}
+
+func TestOpenAPIMethodsAndShapes(t *testing.T) {
+	mux := NewServeMux()
+	// allows no methods
+	mux.Get("/never", stringHandler("never")).Methods("POST")
+	mux.Register("/locked", stringHandler("locked"),
+		NewRejectRule(NewMethodRule("DELETE"), http.StatusMethodNotAllowed, "read only"))
+	mux.Get("/users/:id", stringHandler("user"))
+	mux.Delete("/users/:name", stringHandler("delete user"))
+	mux.Put("/users/:name/keys/:key", stringHandler("put key"))
+	doc := mux.OpenAPI(OpenAPIInfo{Title: "Test", Version: "1.0.0"})
+
+	if item, ok := doc.Paths["/never"]; ok {
+		t.Errorf("OpenAPI() paths[/never] = %+v, want no path item", item)
+	}
+	// methods wrapped by reject rules still restrict the route
+	locked := doc.Paths["/locked"]
+	if locked == nil || locked.Delete == nil || locked.Get != nil {
+		t.Errorf("OpenAPI() paths[/locked] = %+v, want delete only", locked)
+	}
+	// paths with the same shape share the first route's param names
+	if _, ok := doc.Paths["/users/{name}"]; ok {
+		t.Errorf("OpenAPI() documented /users/{name} separately from /users/{id}")
+	}
+	users := doc.Paths["/users/{id}"]
+	if users == nil || users.Get == nil || users.Delete == nil || users.Delete.Parameters[0].Name != "id" {
+		t.Errorf("OpenAPI() paths[/users/{id}] = %+v, want get and delete with param id", users)
+	}
+	if keys := doc.Paths["/users/{name}/keys/{key}"]; keys == nil || keys.Put == nil {
+		t.Errorf("OpenAPI() paths[/users/{name}/keys/{key}] = %+v, want put", keys)
+	}
+}
+
+func TestOpenAPIHosts(t *testing.T) {
+	mux := NewServeMux()
+	mux.Get("admin.example.com/users", stringHandler("admin users"))
+	mux.Get("api.example.com/users", stringHandler("api users")).Meta("summary", "API users")
+	mux.Get("/users", stringHandler("users")).Meta("summary", "Users")
+	mux.Post("/users", stringHandler("create user"))
+	mux.Get("admin.example.com/audit", stringHandler("audit"))
+	info := OpenAPIInfo{Title: "Test", Version: "1.0.0"}
+
+	// paths are not shared between hosts
+	doc := mux.OpenAPI(info)
+	users := doc.Paths["/users"]
+	if users == nil || users.Servers != nil || users.Get == nil || users.Get.Summary != "Users" || users.Post == nil {
+		t.Errorf("OpenAPI() paths[/users] = %+v, want generic get and post without servers", users)
+	}
+	audit := doc.Paths["/audit"]
+	wantServers := []OpenAPIServer{{URL: "//admin.example.com"}}
+	if audit == nil || audit.Get == nil || !reflect.DeepEqual(audit.Servers, wantServers) {
+		t.Errorf("OpenAPI() paths[/audit] = %+v, want get with servers %v", audit, wantServers)
+	}
+
+	// host documents prefer the host's routes, as matching does
+	doc = mux.OpenAPIHost(info, "API.example.com")
+	wantServers = []OpenAPIServer{{URL: "//api.example.com"}}
+	if !reflect.DeepEqual(doc.Servers, wantServers) {
+		t.Errorf("OpenAPIHost() servers = %v, want %v", doc.Servers, wantServers)
+	}
+	users = doc.Paths["/users"]
+	if users == nil || users.Servers != nil || users.Get == nil || users.Get.Summary != "API users" || users.Post == nil {
+		t.Errorf("OpenAPIHost() paths[/users] = %+v, want api get and generic post", users)
+	}
+	if _, ok := doc.Paths["/audit"]; ok {
+		t.Errorf("OpenAPIHost() documented admin.example.com route /audit")
+	}
+}
+
+func TestOpenAPIHandler(t *testing.T) {
+	mux := NewServeMux()
+	mux.Get("/openapi.json", OpenAPIHandler(mux, OpenAPIInfo{Title: "Test", Version: "1.0.0"}))
+	mux.Get("/users/:id", stringHandler("user"))
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, newRequest("GET", "/openapi.json"))
+	if contentType := w.Header().Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("GET /openapi.json -> Content-Type %q, want application/json", contentType)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("GET /openapi.json -> invalid JSON: %v", err)
+	}
+	paths, _ := doc["paths"].(map[string]interface{})
+	if _, ok := paths["/users/{id}"]; !ok || len(paths) != 2 {
+		t.Errorf("GET /openapi.json -> paths %v, want /openapi.json and /users/{id}", paths)
+	}
+}
@@ -0,0 +1,180 @@
+package warp
+
+import (
+	"net/url"
+	"strings"
+)
+
+// hasOptional returns true if the pattern contains an optional group.
+func hasOptional(pattern string) bool {
+	return openingParen(pattern) >= 0
+}
+
+// hasEscape returns true if the pattern contains an escaped paren or
+// backslash.
+func hasEscape(pattern string) bool {
+	for i := 0; i < len(pattern); i++ {
+		if isEscape(pattern, i) {
+			return true
+		}
+	}
+	return false
+}
+
+// isEscape returns true if the byte at index i of the pattern is a backslash
+// escaping the paren or backslash following it.
+func isEscape(pattern string, i int) bool {
+	return pattern[i] == '\\' && i+1 < len(pattern) && strings.IndexByte("()\\", pattern[i+1]) >= 0
+}
+
+// unescapeParens returns the pattern with each escaped paren or backslash
+// replaced by the literal rune it escapes.
+func unescapeParens(pattern string) string {
+	if !hasEscape(pattern) {
+		return pattern
+	}
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		if isEscape(pattern, i) {
+			i++
+		}
+		b.WriteByte(pattern[i])
+	}
+	return b.String()
+}
+
+// openingParen returns the index of the first paren of the pattern opening
+// an optional group, or -1 if the pattern has no optional groups.
+func openingParen(pattern string) int {
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case isEscape(pattern, i):
+			i++
+		case pattern[i] == '(':
+			return i
+		}
+	}
+	return -1
+}
+
+// validOptional returns true if the optional groups of the pattern are
+// balanced and non-empty.
+func validOptional(pattern string) bool {
+	var depth int
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case isEscape(pattern, i):
+			i++
+		case pattern[i] == '(':
+			if i+1 < len(pattern) && pattern[i+1] == ')' {
+				return false
+			}
+			depth++
+		case pattern[i] == ')':
+			depth--
+			if depth < 0 {
+				return false
+			}
+		}
+	}
+	return depth == 0
+}
+
+// expandOptional returns the patterns formed by including or excluding each
+// optional group of the pattern, with escaped parens and backslashes
+// unescaped. Patterns including a group are listed before patterns
+// excluding it. For example, /reports/:year(/:month) expands to
+// /reports/:year/:month and /reports/:year. Groups may be nested.
+func expandOptional(pattern string) []string {
+	expanded := expandGroups(pattern)
+	for i, e := range expanded {
+		expanded[i] = unescapeParens(e)
+	}
+	return expanded
+}
+
+// expandGroups expands the optional groups of the pattern, as expandOptional
+// does, keeping escapes.
+func expandGroups(pattern string) []string {
+	i := openingParen(pattern)
+	if i < 0 {
+		return []string{pattern}
+	}
+	j := closingParen(pattern, i)
+	prefix, group, rest := pattern[:i], pattern[i+1:j], pattern[j+1:]
+	var expanded []string
+	rests := expandGroups(rest)
+	for _, inner := range expandGroups(group) {
+		for _, r := range rests {
+			expanded = append(expanded, prefix+inner+r)
+		}
+	}
+	for _, r := range rests {
+		expanded = append(expanded, prefix+r)
+	}
+	return expanded
+}
+
+// closingParen returns the index of the paren closing the group opened at
+// index i of a valid pattern.
+func closingParen(pattern string, i int) int {
+	var depth int
+	for j := i; j < len(pattern); j++ {
+		switch {
+		case isEscape(pattern, j):
+			j++
+		case pattern[j] == '(':
+			depth++
+		case pattern[j] == ')':
+			depth--
+			if depth == 0 {
+				return j
+			}
+		}
+	}
+	return len(pattern)
+}
+
+// optionalPathMatch matches the path against each of the expansions of a
+// pattern's optional groups and escapes, as computed by expandOptional, and
+// returns the match of the expansion which matched the most runes,
+// preferring expansions which include groups on ties, along with that
+// expansion. Patterns without optional groups or escapes have nil
+// expansions and are matched as by foldPathMatch.
+func optionalPathMatch(pattern string, expansions []string, path string, fold bool) (bool, int, url.Values, string) {
+	if expansions == nil {
+		isMatch, runeCount, params := foldPathMatch(pattern, path, fold)
+		return isMatch, runeCount, params, pattern
+	}
+	var best string
+	var bestCount = -1
+	var bestParams url.Values
+	for _, expanded := range expansions {
+		isMatch, runeCount, params := foldPathMatch(expanded, path, fold)
+		if isMatch && runeCount > bestCount {
+			best, bestCount, bestParams = expanded, runeCount, params
+		}
+	}
+	if bestCount < 0 {
+		return false, 0, nil, ""
+	}
+	return true, bestCount, bestParams, best
+}
+
+// addExpansions records the expansions of the path patterns of the key
+// which need expanding: its path, its escaped path and their trailing slash
+// twins.
+func addExpansions(expansions map[string][]string, key, escapedKey string) {
+	_, path := splitPattern(key)
+	_, escapedPath := splitPattern(escapedKey)
+	for _, p := range []string{path, escapedPath} {
+		if p == "" {
+			continue
+		}
+		for _, twin := range []string{p, strings.TrimSuffix(p, "/")} {
+			if _, ok := expansions[twin]; !ok && (hasOptional(twin) || hasEscape(twin)) {
+				expansions[twin] = expandOptional(twin)
+			}
+		}
+	}
+}
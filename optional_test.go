@@ -0,0 +1,87 @@
+package warp
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+var expandOptionalTests = []struct {
+	pattern  string
+	expanded []string
+}{
+	{"/reports/:year", []string{"/reports/:year"}},
+	{"/reports/:year(/:month)", []string{"/reports/:year/:month", "/reports/:year"}},
+	{"/a(/:b(/:c))", []string{"/a/:b/:c", "/a/:b", "/a"}},
+	{"/a(/b)/c(/d)", []string{"/a/b/c/d", "/a/b/c", "/a/c/d", "/a/c"}},
+	// escaped parens and backslashes are literals
+	{`/wiki/Go_\(lang\)`, []string{"/wiki/Go_(lang)"}},
+	{`/docs/\(draft(/:page)\)`, []string{"/docs/(draft/:page)", "/docs/(draft)"}},
+	{`/a\\(/b)`, []string{`/a\/b`, `/a\`}},
+	{`/a\b`, []string{`/a\b`}},
+}
+
+func TestExpandOptional(t *testing.T) {
+	for _, et := range expandOptionalTests {
+		if expanded := expandOptional(et.pattern); !reflect.DeepEqual(expanded, et.expanded) {
+			t.Errorf("expandOptional(%q) = %v, want %v", et.pattern, expanded, et.expanded)
+		}
+	}
+}
+
+func TestInvalidOptionalPanics(t *testing.T) {
+	for _, pattern := range []string{"/a(/b", "/a)/b(", "/a()", "/a(/b))", `/a\((/b`, `/a\\(/b`} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("registering %q did not panic", pattern)
+				}
+			}()
+			NewServeMux().Handle(pattern, stringHandler("invalid"))
+		}()
+	}
+}
+
+var escapedParenTests = []struct {
+	path    string
+	code    int
+	pattern string
+}{
+	{"/wiki/Go_(lang)", 200, `/wiki/Go_\(lang\)`},
+	{"/wiki/Go_lang", 404, ""},
+	{"/wiki/Go_", 404, ""},
+	{"/docs/(draft)/intro", 200, `/docs/\(draft\)(/:page)`},
+	{"/docs/(draft)", 200, `/docs/\(draft\)(/:page)`},
+	{"/docs/draft", 404, ""},
+}
+
+func TestEscapedParens(t *testing.T) {
+	for _, useEscapedPath := range []bool{false, true} {
+		mux := NewServeMux()
+		mux.UseEscapedPath = useEscapedPath
+		mux.Handle(`/wiki/Go_\(lang\)`, stringHandler("wiki"))
+		mux.Handle(`/docs/\(draft\)(/:page)`, stringHandler("draft"))
+		for _, et := range escapedParenTests {
+			w := httptest.NewRecorder()
+			r := newRequest("GET", et.path)
+			mux.ServeHTTP(w, r)
+			if _, pattern := mux.Handler(r); w.Code != et.code || pattern != et.pattern {
+				t.Errorf("UseEscapedPath %v: GET %s -> %d %q, want %d %q", useEscapedPath, et.path, w.Code, pattern, et.code, et.pattern)
+			}
+		}
+	}
+}
+
+func TestExpansionsPrecomputed(t *testing.T) {
+	mux := NewServeMux()
+	mux.Handle("/reports/:year(/:month)/", stringHandler("reports"))
+	mux.Handle("/plain", stringHandler("plain"))
+	for _, path := range []string{"/reports/:year(/:month)/", "/reports/:year(/:month)"} {
+		if _, ok := mux.expansions[path]; !ok {
+			t.Errorf("expansions of %q not computed at registration", path)
+		}
+	}
+	if _, ok := mux.expansions["/plain"]; ok {
+		t.Errorf("expansions of /plain computed, want only patterns with groups or escapes")
+	}
+}
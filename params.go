@@ -0,0 +1,81 @@
+package warp
+
+import (
+	"net/http"
+	"strings"
+)
+
+// splatName returns the param name of the *name splat capture starting at
+// the given rune index of the pattern, or an empty string if there is none. A
+// splat starts a path segment and captures the remainder of the path, so it
+// must end the pattern.
+func splatName(pattern []rune, i int) string {
+	if pattern[i] != '*' || i == 0 || pattern[i-1] != '/' {
+		return ""
+	}
+	name, next, _ := captureName(pattern, i+1)
+	if next != len(pattern) {
+		return ""
+	}
+	return name
+}
+
+// isEscapedStar returns true if the rune at the given index of the pattern is
+// a backslash escaping a literal '*', as in "/files/\*files", which never
+// starts a splat capture.
+func isEscapedStar(pattern []rune, i int) bool {
+	return pattern[i] == '\\' && i+1 < len(pattern) && pattern[i+1] == '*'
+}
+
+// validSplat returns false if the path pattern has a *name capture which
+// does not end the pattern (e.g. "/tags/*tags/count"), or one of its optional
+// group expansions.
+func validSplat(pattern string) bool {
+	for _, expanded := range expandOptional(pattern) {
+		rPattern := []rune(expanded)
+		for i := 1; i < len(rPattern); i++ {
+			if rPattern[i] != '*' || rPattern[i-1] != '/' {
+				continue
+			}
+			name, _, _ := captureName(rPattern, i+1)
+			if name != "" && splatName(rPattern, i) == "" {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// splatLength returns the length of the path pattern, counting a trailing
+// *name splat capture as a single rune, so that patterns capturing one
+// segment are preferred over splats capturing the same path.
+func splatLength(pattern string) int {
+	rPattern := []rune(pattern)
+	for i := range rPattern {
+		if splatName(rPattern, i) != "" {
+			return len(string(rPattern[:i+1]))
+		}
+	}
+	return len(pattern)
+}
+
+// captureSegments captures the segments of the path starting at the given
+// rune index, ignoring a trailing slash. Returns nil if no segments remain.
+func captureSegments(path []rune, j int) []string {
+	rest := strings.TrimSuffix(string(path[j:]), "/")
+	if rest == "" {
+		return nil
+	}
+	return strings.Split(rest, "/")
+}
+
+// ParamValues returns the values captured for the named param, without its
+// ':' prefix, in the order they appear in the matched request path. Patterns
+// which repeat a param name, as in "/compare/:id/:id", capture one value per
+// occurrence, and *name splat captures, as in "/tags/*tags", capture one
+// value per remaining path segment. Returns nil if the param was not
+// captured. Values clients send in the query string under the ':' prefixed
+// name are ignored.
+func ParamValues(request *http.Request, name string) []string {
+	return RequestParams(request)[":"+name]
+}
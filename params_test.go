@@ -0,0 +1,119 @@
+package warp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var splatMatchTests = []struct {
+	pattern   string
+	path      string
+	isMatch   bool
+	runeCount int
+	params    url.Values
+}{
+	{"/tags/*tags", "/tags/go", true, 6, url.Values{":tags": {"go"}}},
+	{"/tags/*tags", "/tags/go/http/mux", true, 6, url.Values{":tags": {"go", "http", "mux"}}},
+	{"/tags/*tags", "/tags/go/http/", true, 6, url.Values{":tags": {"go", "http"}}},
+	{"/tags/*tags", "/tags/", false, 6, nil},
+	{"/tags/*tags", "/tags", false, 5, nil},
+	{"/:user/files/*path", "/ben/files/a/b", true, 8, url.Values{":user": {"ben"}, ":path": {"a", "b"}}},
+	// '*' is literal unless it begins a final named segment
+	{"/glob/*", "/glob/*", true, 7, nil},
+	{"/glob/*.txt", "/glob/*.txt", true, 11, nil},
+	// an escaped '*' is literal, even where it would begin a splat
+	{`/files/\*files`, "/files/*files", true, 13, nil},
+	{`/files/\*files`, "/files/a", false, 7, nil},
+	{`/files/\*files`, `/files/\*files`, false, 7, nil},
+}
+
+func TestSplatMatch(t *testing.T) {
+	for _, st := range splatMatchTests {
+		isMatch, runeCount, params := pathMatch(st.pattern, st.path)
+		if isMatch != st.isMatch || runeCount != st.runeCount {
+			t.Errorf("pathMatch(%q, %q) = %v, %d, want %v, %d", st.pattern, st.path, isMatch, runeCount, st.isMatch, st.runeCount)
+		}
+		if st.params != nil && !reflect.DeepEqual(params, st.params) {
+			t.Errorf("pathMatch(%q, %q) params %v, want %v", st.pattern, st.path, params, st.params)
+		}
+	}
+}
+
+func TestEscapedStar(t *testing.T) {
+	mux := NewServeMux()
+	mux.Get(`/files/\*files`, stringHandler("literal"))
+	mux.Get("/files/*path", stringHandler("splat"))
+
+	cases := []struct {
+		url    string
+		result string
+	}{
+		{"/files/*files", "literal"},
+		{"/files/%2Afiles", "literal"},
+		{"/files/a/b", "splat"},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, newRequest("GET", c.url))
+		if result := w.Header().Get("Result"); result != c.result {
+			t.Errorf("GET %s -> %q, want %q", c.url, result, c.result)
+		}
+	}
+	if path, _, _ := openAPIPath(`/files/\*files`); path != "/files/*files" {
+		t.Errorf("openAPIPath(/files/\\*files) = %q, want /files/*files", path)
+	}
+}
+
+func TestInvalidSplatPanics(t *testing.T) {
+	for _, pattern := range []string{"/tags/*tags/count", "/files/*name.txt", "/a(/*b)/c"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("registering %q did not panic", pattern)
+				}
+			}()
+			NewServeMux().Handle(pattern, stringHandler("invalid"))
+		}()
+	}
+}
+
+var paramValuesTests = []struct {
+	url    string // test request url
+	name   string // param name
+	values []string
+}{
+	{"/compare/a/b", "id", []string{"a", "b"}},
+	{"/tags/go/http", "tags", []string{"go", "http"}},
+	{"/tags/go%2Fhttp/mux", "tags", []string{"go/http", "mux"}},
+	// single segment captures are preferred over splats
+	{"/tags/go", "tag", []string{"go"}},
+	{"/tags/go", "tags", nil},
+	// ':' prefixed query params cannot inject values
+	{"/compare/a/b?%3Aid=evil", "id", []string{"a", "b"}},
+	{"/tags/go?%3Atags=x", "tags", nil},
+}
+
+func TestParamValues(t *testing.T) {
+	mux := NewServeMux()
+	mux.UseEscapedPath = true
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Result", strings.Join(ParamValues(r, r.Header.Get("Name")), ","))
+	}
+	mux.HandleFunc("/compare/:id/:id", handler)
+	mux.HandleFunc("/tags/*tags", handler)
+	mux.HandleFunc("/tags/:tag", handler)
+
+	for _, pt := range paramValuesTests {
+		r := newRequest("GET", pt.url)
+		r.Header.Set("Name", pt.name)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if result := w.Header().Get("Result"); result != strings.Join(pt.values, ",") {
+			t.Errorf("GET %s -> ParamValues(%q) %q, want %q", pt.url, pt.name, result, strings.Join(pt.values, ","))
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package warp
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/dghubble/warp/internal/response"
+)
+
+// PanicError is the error ServeError is passed when Recover recovers a
+// panic serving a request.
+type PanicError struct {
+	Value   interface{} // value passed to panic
+	Pattern string      // pattern of the matched route, empty if none matched
+	Stack   []byte      // stack of the panicking goroutine
+}
+
+func (err *PanicError) Error() string {
+	if err.Pattern == "" {
+		return fmt.Sprintf("warp: panic serving request: %v", err.Value)
+	}
+	return fmt.Sprintf("warp: panic serving %s: %v", err.Pattern, err.Value)
+}
+
+// Unwrap returns the panic value, if it is an error.
+func (err *PanicError) Unwrap() error {
+	unwrapped, _ := err.Value.(error)
+	return unwrapped
+}
+
+// Recover is Middleware which recovers panics in the handlers it wraps. The
+// panic is logged to the standard logger with its stack and the pattern of
+// the matched route, and passed as a *PanicError to ServeError, which
+// responds with the route's or mux's ErrorHandler (500 Internal Server Error
+// by default). Add it first, so it also recovers panics in the middleware
+// added after it:
+//
+//	mux.Use(warp.Recover)
+//
+// If the handler had already written the response header, the response
+// cannot be replaced, so the connection is aborted instead. Panics with
+// http.ErrAbortHandler are passed on to net/http to abort the connection.
+func Recover(next http.Handler) http.Handler {
+	return RecoverWith(log.Default())(next)
+}
+
+// RecoverWith returns Middleware which recovers panics as Recover does, but
+// logs them to the logger, or not at all if the logger is nil, as when the
+// ErrorHandler reports the PanicError itself:
+//
+//	mux.Use(warp.RecoverWith(log.New(os.Stderr, "notes: ", log.LstdFlags)))
+func RecoverWith(logger *log.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			recorder := response.NewRecorder(w)
+			defer func() {
+				value := recover()
+				if value == nil {
+					return
+				}
+				if value == http.ErrAbortHandler {
+					panic(value)
+				}
+				err := &PanicError{Value: value, Pattern: RequestPattern(req), Stack: debug.Stack()}
+				if logger != nil {
+					logger.Printf("%v\n%s", err, err.Stack)
+				}
+				if recorder.WroteHeader {
+					panic(http.ErrAbortHandler)
+				}
+				ServeError(w, req, err)
+			}()
+			next.ServeHTTP(recorder, req)
+		})
+	}
+}
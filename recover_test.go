@@ -0,0 +1,87 @@
+package warp
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func panicHandler(value interface{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		panic(value)
+	})
+}
+
+func TestRecover(t *testing.T) {
+	var logged bytes.Buffer
+	errBoom := errors.New("boom")
+	var recovered error
+	mux := NewServeMux()
+	mux.Use(RecoverWith(log.New(&logged, "", 0)))
+	mux.ErrorHandler = func(w http.ResponseWriter, req *http.Request, err error) {
+		recovered = err
+		TextErrorHandler(w, req, err)
+	}
+	mux.Get("/text/:id", panicHandler(errBoom))
+	mux.Get("/json", panicHandler("oops")).ErrorHandler(ProblemErrorHandler)
+	mux.Get("/html", panicHandler("oops")).ErrorHandler(HTMLErrorHandler)
+	mux.Get("/ok", stringHandler("ok"))
+
+	cases := []struct {
+		url         string
+		code        int
+		contentType string
+	}{
+		{"/text/1", 500, "text/plain; charset=utf-8"},
+		{"/json", 500, "application/problem+json"},
+		{"/html", 500, "text/html; charset=utf-8"},
+		{"/ok", 200, ""},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, newRequest("GET", c.url))
+		if w.Code != c.code || w.Header().Get("Content-Type") != c.contentType {
+			t.Errorf("GET %s -> %d %q, want %d %q", c.url, w.Code, w.Header().Get("Content-Type"), c.code, c.contentType)
+		}
+		if strings.Contains(w.Body.String(), "oops") || strings.Contains(w.Body.String(), "boom") {
+			t.Errorf("GET %s -> body %q exposes the panic", c.url, w.Body.String())
+		}
+	}
+
+	var panicErr *PanicError
+	if !errors.As(recovered, &panicErr) || panicErr.Pattern != "/text/:id" || len(panicErr.Stack) == 0 {
+		t.Fatalf("mux ErrorHandler passed %#v, want *PanicError for /text/:id with stack", recovered)
+	}
+	if !errors.Is(recovered, errBoom) {
+		t.Errorf("PanicError does not unwrap to the panic value")
+	}
+	if !strings.Contains(logged.String(), "warp: panic serving /text/:id: boom") || !strings.Contains(logged.String(), "goroutine") {
+		t.Errorf("Recover logged %q, want panic with stack", logged.String())
+	}
+}
+
+func TestRecoverAbort(t *testing.T) {
+	mux := NewServeMux()
+	// panics are not logged with a nil logger
+	mux.Use(RecoverWith(nil))
+	mux.Get("/abort", panicHandler(http.ErrAbortHandler))
+	mux.Get("/partial", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("partial"))
+		panic("oops")
+	}))
+
+	for _, url := range []string{"/abort", "/partial"} {
+		func() {
+			defer func() {
+				if value := recover(); value != http.ErrAbortHandler {
+					t.Errorf("GET %s panicked with %v, want http.ErrAbortHandler", url, value)
+				}
+			}()
+			mux.ServeHTTP(httptest.NewRecorder(), newRequest("GET", url))
+		}()
+	}
+}
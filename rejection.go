@@ -0,0 +1,90 @@
+package warp
+
+import (
+	"net/http"
+)
+
+// Rejection describes why a Rule rejected a request and how ServeMux should
+// respond when no route allows the request.
+type Rejection struct {
+	Status  int          // HTTP status code, 404 Not Found if zero
+	Reason  string       // why the request was rejected (e.g. "ip not allowed")
+	Handler http.Handler // optional handler to respond with instead
+}
+
+// ServeHTTP responds with the Rejection Handler, if set, or else replies
+// with the Rejection Status and Reason.
+func (rejection *Rejection) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if rejection.Handler != nil {
+		rejection.Handler.ServeHTTP(w, req)
+		return
+	}
+	status := rejection.status()
+	switch {
+	case rejection.Reason != "":
+		http.Error(w, rejection.Reason, status)
+	case status == http.StatusNotFound:
+		http.NotFound(w, req)
+	default:
+		http.Error(w, http.StatusText(status), status)
+	}
+}
+
+// status returns the Rejection status, defaulting to 404 Not Found.
+func (rejection *Rejection) status() int {
+	if rejection.Status == 0 {
+		return http.StatusNotFound
+	}
+	return rejection.Status
+}
+
+// RejectRule is implemented by Rules which report why they rejected a
+// request. When routes match the request path but none allows the request,
+// ServeMux responds with the Rejection of the first failing Rule of the
+// closest route, the route matching the most runes and passing the most
+// Rules. Rules which do not implement RejectRule reject with 404 Not Found.
+type RejectRule interface {
+	Rule
+	Reject(*http.Request) *Rejection
+}
+
+// NewRejectRule wraps the Rule so that requests it does not allow are
+// rejected with the given status and reason:
+//
+//	mux.Get("/admin", adminHandler).Rules(
+//		warp.NewRejectRule(authRule, http.StatusUnauthorized, "missing auth header"),
+//		warp.NewRejectRule(ipRule, http.StatusForbidden, "ip not allowed"),
+//	)
+func NewRejectRule(rule Rule, status int, reason string) RejectRule {
+	return &rejectRule{rule, &Rejection{Status: status, Reason: reason}}
+}
+
+// rejectRule is a Rule with a fixed Rejection.
+type rejectRule struct {
+	Rule
+	rejection *Rejection
+}
+
+// Match delegates to the wrapped Rule, preserving the request it derives if
+// it is a MatchRule.
+func (rule *rejectRule) Match(request *http.Request) (*http.Request, bool) {
+	if matcher, ok := rule.Rule.(MatchRule); ok {
+		return matcher.Match(request)
+	}
+	return request, rule.Rule.Allows(request)
+}
+
+// Reject returns the fixed Rejection.
+func (rule *rejectRule) Reject(request *http.Request) *Rejection {
+	return rule.rejection
+}
+
+// rejectionOf returns the Rejection for the Rule which rejected the request.
+func rejectionOf(rule Rule, request *http.Request) *Rejection {
+	if rule, ok := rule.(RejectRule); ok {
+		if rejection := rule.Reject(request); rejection != nil {
+			return rejection
+		}
+	}
+	return &Rejection{Status: http.StatusNotFound}
+}
@@ -0,0 +1,84 @@
+package warp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// test rule rejection reasons and responses
+
+var authRule = NewRejectRule(headerRule("Authorization"), http.StatusUnauthorized, "missing auth header")
+
+var localRule = NewRejectRule(RuleFunc(func(request *http.Request) bool {
+	return strings.HasPrefix(request.RemoteAddr, "127.0.0.1:")
+}), http.StatusForbidden, "ip not allowed")
+
+// RuleFunc adapts a function to a Rule
+type RuleFunc func(*http.Request) bool
+
+func (f RuleFunc) Allows(request *http.Request) bool {
+	return f(request)
+}
+
+func registerRejectionRoutes(mux *ServeMux) {
+	mux.Get("/admin/", stringHandler("admin")).Rules(authRule, localRule)
+	mux.Get("/admin/reports", stringHandler("reports")).Rules(authRule).Accept("text/csv")
+	mux.Post("/admin/reports", stringHandler("upload")).Rules(authRule).ContentType("text/csv")
+	mux.Get("/teapot", stringHandler("teapot")).Rules(NewRejectRule(headerRule("X-Tea"), http.StatusTeapot, ""))
+	mux.Get("/custom", stringHandler("custom")).Rules(NewRejectRule(headerRule("X-Custom"), 0, "custom reason"))
+	mux.Get("/handled", stringHandler("handled")).Rules(&handledRule{})
+}
+
+// handledRule rejects all requests with a custom handler
+type handledRule struct{}
+
+func (rule *handledRule) Allows(request *http.Request) bool {
+	return false
+}
+
+func (rule *handledRule) Reject(request *http.Request) *Rejection {
+	return &Rejection{Handler: serve(http.StatusPaymentRequired)}
+}
+
+var rejectionTests = []struct {
+	method  string   // test request method
+	url     string   // test request url
+	headers []string // request headers to set
+	code    int      // expected HTTP response code
+	body    string   // expected response body
+}{
+	{"GET", "/admin/", nil, 401, "missing auth header\n"},
+	{"GET", "/admin/", []string{"Authorization"}, 403, "ip not allowed\n"},
+	// closest route (more runes, more rules passed) decides the rejection
+	{"GET", "/admin/reports", nil, 401, "missing auth header\n"},
+	{"GET", "/admin/reports", []string{"Authorization", "Accept"}, 406, "not acceptable: text/csv\n"},
+	{"POST", "/admin/reports", []string{"Authorization", "Content-Type"}, 415, "unsupported media type application/json\n"},
+	// rules without reasons reject with 404 Not Found
+	{"DELETE", "/admin/reports", nil, 404, "404 page not found\n"},
+	{"GET", "/teapot", nil, 418, "I'm a teapot\n"},
+	{"GET", "/custom", nil, 404, "custom reason\n"},
+	{"GET", "/handled", nil, 402, ""},
+}
+
+func TestServeHTTPRejections(t *testing.T) {
+	mux := NewServeMux()
+	registerRejectionRoutes(mux)
+
+	for _, rt := range rejectionTests {
+		r := newRequest(rt.method, rt.url)
+		r.RemoteAddr = "10.0.0.1:1234"
+		for _, header := range rt.headers {
+			r.Header.Set(header, "application/json")
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != rt.code {
+			t.Errorf("%s %s %v -> code %d, want %d", rt.method, rt.url, rt.headers, w.Code, rt.code)
+		}
+		if body := w.Body.String(); body != rt.body {
+			t.Errorf("%s %s %v -> body %q, want %q", rt.method, rt.url, rt.headers, body, rt.body)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package warp
+
+// ReplaceRoutes atomically replaces the routes of the mux with the routes of
+// src, so each request is matched against either all of the previous routes
+// or all of the new ones. The options of the mux, such as TrailingSlash, are
+// kept. Unlike ReplaceSource, every route is replaced, including routes
+// registered in code. src shares its routes with the mux and should not be
+// used afterwards.
+func (mux *ServeMux) ReplaceRoutes(src *ServeMux) {
+	if src == mux {
+		return
+	}
+	src.mu.RLock()
+	routes, escaped, expansions, anyHosts := src.routes, src.escaped, src.expansions, src.anyHosts
+	src.mu.RUnlock()
+
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+	mux.routes, mux.escaped, mux.expansions, mux.anyHosts = routes, escaped, expansions, anyHosts
+}
+
+// ReplaceSource atomically replaces the routes of the mux which were added
+// from the source, such as a config file name, by an earlier ReplaceSource
+// with the routes of src, keeping routes registered in code or added from
+// other sources. Routes added from the source are ordered after the other
+// routes for the same pattern. src shares its routes with the mux and should
+// not be used afterwards.
+//
+//	loaded := warp.NewServeMux()
+//	loaded.Get("/reports/:id", reportsHandler)
+//	mux.ReplaceSource("routes.yaml", loaded)
+func (mux *ServeMux) ReplaceSource(source string, src *ServeMux) {
+	if src == mux {
+		return
+	}
+	src.mu.Lock()
+	srcRoutes, srcEscaped := src.routes, src.escaped
+	for _, keyRoutes := range srcRoutes {
+		for _, route := range keyRoutes {
+			route.source = source
+		}
+	}
+	src.mu.Unlock()
+
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+	routes := make(map[string][]*Route, len(mux.routes))
+	escaped := make(map[string]string, len(mux.escaped))
+	for key, keyRoutes := range mux.routes {
+		var kept []*Route
+		for _, route := range keyRoutes {
+			if route.source != source {
+				kept = append(kept, route)
+			}
+		}
+		if len(kept) > 0 {
+			routes[key], escaped[key] = kept, mux.escaped[key]
+		}
+	}
+	for key, keyRoutes := range srcRoutes {
+		routes[key] = append(routes[key], keyRoutes...)
+		escaped[key] = srcEscaped[key]
+	}
+	mux.anyHosts = false
+	expansions := make(map[string][]string)
+	for key := range routes {
+		if host, _ := splitPattern(key); host != "" {
+			mux.anyHosts = true
+		}
+		addExpansions(expansions, key, escaped[key])
+	}
+	mux.routes, mux.escaped, mux.expansions = routes, escaped, expansions
+}
@@ -0,0 +1,55 @@
+package warp
+
+import (
+	"testing"
+)
+
+func TestReplaceRoutes(t *testing.T) {
+	mux := NewServeMux()
+	mux.Get("/old", stringHandler("old"))
+	src := NewServeMux()
+	src.Get("example.com/new", stringHandler("new"))
+	mux.ReplaceRoutes(src)
+
+	for url, want := range map[string]string{"/old": "", "http://example.com/new": "example.com/new"} {
+		if _, pattern := mux.Handler(newRequest("GET", url)); pattern != want {
+			t.Errorf("GET %s -> pattern %q, want %q", url, pattern, want)
+		}
+	}
+}
+
+func TestReplaceSource(t *testing.T) {
+	mux := NewServeMux()
+	mux.Get("/kept", stringHandler("kept"))
+	mux.Get("/shared", stringHandler("code"))
+
+	v1 := NewServeMux()
+	v1.Get("/v1", stringHandler("v1"))
+	v1.Get("/shared", stringHandler("v1"))
+	mux.ReplaceSource("routes.yaml", v1)
+	other := NewServeMux()
+	other.Get("/other", stringHandler("other"))
+	mux.ReplaceSource("other.yaml", other)
+	v2 := NewServeMux()
+	v2.Get("/v2(/:id)", stringHandler("v2"))
+	mux.ReplaceSource("routes.yaml", v2)
+
+	cases := []struct {
+		url     string
+		pattern string
+	}{
+		{"/kept", "/kept"},
+		{"/other", "/other"},
+		{"/v1", ""},
+		{"/v2/42", "/v2(/:id)"},
+		{"/shared", "/shared"},
+	}
+	for _, c := range cases {
+		if _, pattern := mux.Handler(newRequest("GET", c.url)); pattern != c.pattern {
+			t.Errorf("GET %s -> pattern %q, want %q", c.url, pattern, c.pattern)
+		}
+	}
+	if routes := mux.Routes(); len(routes) != 4 {
+		t.Errorf("Routes() = %v, want 4 routes", routes)
+	}
+}
@@ -9,28 +9,107 @@ import (
 type Route struct {
 	pattern  string       // pattern to report that the request matched
 	handler  http.Handler // handler for the route
-	implicit bool         // true for implicit routes added by ServeMux
 	rules    []Rule       // route Rules
+	priority int          // rank among routes for the same pattern
+	// format extensions and their media types
+	formats     []string
+	formatTypes []string
+	// metadata for middleware and introspection
+	meta map[string]interface{}
+	tags []string
+	// responds to errors serving the route, overriding the mux's
+	errorHandler ErrorHandler
+	source       string // source the route was added from by ReplaceSource, if any
 }
 
 // NewRoute allocates and returns a new *Route.
 func NewRoute(pattern string, handler http.Handler, rules ...Rule) *Route {
 	return &Route{
-		pattern:  pattern,
-		handler:  handler,
-		implicit: false,
-		rules:    rules,
+		pattern: pattern,
+		handler: handler,
+		rules:   rules,
 	}
 }
 
 // Allows returns true if each of its Rules Allows the request.
 func (route *Route) Allows(request *http.Request) bool {
+	_, ok := route.Match(request)
+	return ok
+}
+
+// Match returns true if each of its Rules allows the request, along with the
+// request as derived by its MatchRules, in order. Each Rule is passed the
+// request derived by the MatchRules before it.
+func (route *Route) Match(request *http.Request) (*http.Request, bool) {
+	derived, _, rule := route.reject(request)
+	return derived, rule == nil
+}
+
+// reject returns the request derived by the route's MatchRules, the first of
+// the route's Rules which does not allow the request, and the number of Rules
+// which allowed the request before it. Returns a nil Rule if every Rule
+// allows the request.
+func (route *Route) reject(request *http.Request) (*http.Request, int, Rule) {
+	for i, rule := range route.rules {
+		if matcher, ok := rule.(MatchRule); ok {
+			derived, ok := matcher.Match(request)
+			if !ok {
+				return request, i, rule
+			}
+			if derived != nil {
+				request = derived
+			}
+		} else if !rule.Allows(request) {
+			return request, i, rule
+		}
+	}
+	return request, len(route.rules), nil
+}
+
+// quality returns the product of the qualities of the route's content
+// negotiation Rules, or 0 if the route has no such Rules.
+func (route *Route) quality(request *http.Request) float64 {
+	var quality float64
 	for _, rule := range route.rules {
-		if !rule.Allows(request) {
-			return false
+		if rule, ok := rule.(qualityRule); ok {
+			if quality == 0 {
+				quality = 1
+			}
+			quality *= rule.Quality(request)
 		}
 	}
-	return true
+	return quality
+}
+
+// methods returns the methods allowed by all of the route's method Rules, or
+// nil if the route has no method Rules and allows any method.
+func (route *Route) methods() []string {
+	var methods []string
+	var restricted bool
+	for _, rule := range route.rules {
+		// method rules wrapped by NewRejectRule still restrict methods
+		if reject, ok := rule.(*rejectRule); ok {
+			rule = reject.Rule
+		}
+		if rule, ok := rule.(methodRule); ok {
+			if !restricted {
+				methods = append([]string{}, rule...)
+				restricted = true
+				continue
+			}
+			var allowed []string
+			for _, method := range methods {
+				if contains(rule, method) {
+					allowed = append(allowed, method)
+				}
+			}
+			methods = allowed
+		}
+	}
+	if restricted && methods == nil {
+		return []string{}
+	}
+	return methods
 }
 
 // Methods adds a MethodRule to the Route to constrain it to
@@ -42,3 +121,43 @@ func (route *Route) Methods(methods ...string) *Route {
 	route.rules = append(route.rules, NewMethodRule(methods...))
 	return route
 }
+
+// Rules adds the given Rules to the Route, which must allow a request in
+// addition to the Route's existing Rules:
+//
+//	mux.Get("/account", accountHandler).Rules(authRule)
+func (route *Route) Rules(rules ...Rule) *Route {
+	route.rules = append(route.rules, rules...)
+	return route
+}
+
+// Priority sets the rank of the Route among routes registered for the same
+// pattern which allow a request. Routes with higher priorities are preferred,
+// the default priority is 0. Among routes with equal priorities, routes with
+// better content negotiation quality and then with more rules are preferred:
+//
+//	mux.Get("/notes", betaHandler).Priority(1)
+//	mux.Get("/notes", listHandler)
+func (route *Route) Priority(priority int) *Route {
+	route.priority = priority
+	return route
+}
+
+// Accept adds an AcceptRule to the Route to constrain it to requests which
+// accept one of the specified media types:
+//
+//	mux.Get("/notes", jsonHandler).Accept("application/json")
+//	mux.Get("/notes", htmlHandler).Accept("text/html")
+func (route *Route) Accept(mediaTypes ...string) *Route {
+	route.rules = append(route.rules, NewAcceptRule(mediaTypes...))
+	return route
+}
+
+// ContentType adds a ContentTypeRule to the Route to constrain it to requests
+// with a body of one of the specified media types:
+//
+//	mux.Post("/notes", createHandler).ContentType("application/json")
+func (route *Route) ContentType(mediaTypes ...string) *Route {
+	route.rules = append(route.rules, NewContentTypeRule(mediaTypes...))
+	return route
+}
@@ -1,6 +1,7 @@
 package warp
 
 import (
+	"mime"
 	"net/http"
 	"strings"
 )
@@ -13,6 +14,39 @@ type Rule interface {
 	Allows(*http.Request) bool
 }
 
+// MatchRule is implemented by Rules which may derive a new request when they
+// allow a request, for example to attach values decoded while checking the
+// request to its context. Match returns the derived request (or the given
+// request) and true if the request passes the rule, or false if the request
+// does not pass. ServeMux dispatches the derived request to the handler.
+type MatchRule interface {
+	Rule
+	Match(*http.Request) (*http.Request, bool)
+}
+
+// MatchFunc is an adapter to allow the use of ordinary functions as
+// MatchRules.
+//
+//	mux.Register("/account", accountHandler, warp.MatchFunc(func(req *http.Request) (*http.Request, bool) {
+//		claims, err := decodeToken(req.Header.Get("Authorization"))
+//		if err != nil {
+//			return req, false
+//		}
+//		return req.WithContext(context.WithValue(req.Context(), claimsKey, claims)), true
+//	}))
+type MatchFunc func(*http.Request) (*http.Request, bool)
+
+// Allows returns true if f allows the request.
+func (f MatchFunc) Allows(request *http.Request) bool {
+	_, ok := f(request)
+	return ok
+}
+
+// Match calls f(request).
+func (f MatchFunc) Match(request *http.Request) (*http.Request, bool) {
+	return f(request)
+}
+
 type methodRule []string
 
 func NewMethodRule(methods ...string) methodRule {
@@ -26,3 +60,94 @@ func NewMethodRule(methods ...string) methodRule {
 func (rule methodRule) Allows(request *http.Request) bool {
 	return contains(rule, request.Method)
 }
+
+// qualityRule is implemented by content negotiation Rules which rank the
+// routes they allow. Quality returns a value between 0 and 1, higher for
+// better matches.
+type qualityRule interface {
+	Rule
+	Quality(*http.Request) float64
+}
+
+type acceptRule []string
+
+// NewAcceptRule returns a Rule that allows requests whose Accept header
+// accepts one of the given media types (e.g. "application/json"). Accept
+// q-values and media range wildcards are honoured and requests without an
+// Accept header accept any media type. When several routes for a pattern
+// allow a request, the route offering the highest quality media type is
+// preferred. If no route accepts the request, ServeMux responds with 406 Not
+// Acceptable.
+func NewAcceptRule(mediaTypes ...string) acceptRule {
+	for i, mediaType := range mediaTypes {
+		mediaTypes[i] = strings.ToLower(mediaType)
+	}
+	return acceptRule(mediaTypes)
+}
+
+// Allows returns true if the request accepts one of the media types.
+func (rule acceptRule) Allows(request *http.Request) bool {
+	return rule.Quality(request) > 0
+}
+
+// Reject returns a 406 Not Acceptable Rejection.
+func (rule acceptRule) Reject(request *http.Request) *Rejection {
+	return &Rejection{Status: http.StatusNotAcceptable, Reason: "not acceptable: " + strings.Join(rule, ", ")}
+}
+
+// Quality returns the highest quality the request Accept header gives to any
+// of the media types.
+func (rule acceptRule) Quality(request *http.Request) float64 {
+	ranges := parseAccept(strings.Join(request.Header["Accept"], ","))
+	var quality float64
+	for _, mediaType := range rule {
+		if q := acceptQuality(ranges, mediaType); q > quality {
+			quality = q
+		}
+	}
+	return quality
+}
+
+type contentTypeRule []string
+
+// NewContentTypeRule returns a Rule that allows requests whose Content-Type
+// matches one of the given media types, which may use wildcards (e.g.
+// "application/json" or "text/*"). Requests without a Content-Type are not
+// allowed. If no route allows the request's Content-Type, ServeMux responds
+// with 415 Unsupported Media Type.
+func NewContentTypeRule(mediaTypes ...string) contentTypeRule {
+	for i, mediaType := range mediaTypes {
+		mediaTypes[i] = strings.ToLower(mediaType)
+	}
+	return contentTypeRule(mediaTypes)
+}
+
+// Allows returns true if the request Content-Type matches one of the media
+// types.
+func (rule contentTypeRule) Allows(request *http.Request) bool {
+	return rule.Quality(request) > 0
+}
+
+// Reject returns a 415 Unsupported Media Type Rejection.
+func (rule contentTypeRule) Reject(request *http.Request) *Rejection {
+	reason := "unsupported media type"
+	if contentType := request.Header.Get("Content-Type"); contentType != "" {
+		reason += " " + contentType
+	}
+	return &Rejection{Status: http.StatusUnsupportedMediaType, Reason: reason}
+}
+
+// Quality returns 1 if the request Content-Type matches one of the media
+// types, 0 otherwise.
+func (rule contentTypeRule) Quality(request *http.Request) float64 {
+	mediaType, _, err := mime.ParseMediaType(request.Header.Get("Content-Type"))
+	if err != nil {
+		return 0
+	}
+	for _, pattern := range rule {
+		if mediaTypeMatch(pattern, mediaType) {
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,211 @@
+package warp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// test content negotiation rules
+
+var acceptQualityTests = []struct {
+	accept    string  // request Accept header
+	mediaType string  // offered media type
+	quality   float64 // expected quality
+}{
+	{"", "application/json", 1},
+	{"application/json", "application/json", 1},
+	{"application/json", "text/html", 0},
+	{"text/html;q=0.8, application/json;q=0.5", "application/json", 0.5},
+	{"text/*;q=0.3, text/html;q=0.7", "text/html", 0.7},
+	{"text/*;q=0.3, text/html;q=0.7", "text/plain", 0.3},
+	{"*/*;q=0.1, application/json", "image/png", 0.1},
+	{"application/json;q=0", "application/json", 0},
+	{"Application/JSON", "application/json", 1},
+	{"garbage, application/json;q=0.4", "application/json", 0.4},
+}
+
+func TestAcceptQuality(t *testing.T) {
+	for _, at := range acceptQualityTests {
+		quality := acceptQuality(parseAccept(at.accept), at.mediaType)
+		if quality != at.quality {
+			t.Errorf("Accept %q offering %s -> quality %v, want %v", at.accept, at.mediaType, quality, at.quality)
+		}
+	}
+}
+
+func registerNegotiationRoutes(mux *ServeMux) {
+	mux.Get("/notes", stringHandler("json")).Accept("application/json")
+	mux.Get("/notes", stringHandler("html")).Accept("text/html")
+	mux.Post("/notes", stringHandler("create")).ContentType("application/json", "text/*")
+	mux.Register("/feed", stringHandler("feed"), NewAcceptRule("application/atom+xml"))
+	mux.Get("/any", stringHandler("fallback"))
+	mux.Get("/any", stringHandler("json only")).Accept("application/json")
+}
+
+var negotiationTests = []struct {
+	method      string // test request method
+	url         string // test request url
+	accept      string // request Accept header
+	contentType string // request Content-Type header
+	code        int    // expected HTTP response code
+	result      string // expected handler result
+}{
+	{"GET", "/notes", "application/json", "", 200, "json"},
+	{"GET", "/notes", "text/html", "", 200, "html"},
+	// best quality match wins
+	{"GET", "/notes", "text/html;q=0.9, application/json;q=0.4", "", 200, "html"},
+	{"GET", "/notes", "text/*;q=0.2, application/*", "", 200, "json"},
+	// no route accepts the request
+	{"GET", "/notes", "image/png", "", 406, ""},
+	{"GET", "/feed", "text/html", "", 406, ""},
+	{"GET", "/feed", "application/atom+xml", "", 200, "feed"},
+	// request body content types
+	{"POST", "/notes", "", "application/json; charset=utf-8", 200, "create"},
+	{"POST", "/notes", "", "text/plain", 200, "create"},
+	{"POST", "/notes", "", "application/xml", 415, ""},
+	{"POST", "/notes", "", "", 415, ""},
+	// method rules still reject with 404
+	{"DELETE", "/notes", "application/json", "", 404, ""},
+	// negotiated routes are preferred over routes without negotiation rules
+	{"GET", "/any", "application/json", "", 200, "json only"},
+	{"GET", "/any", "text/html", "", 200, "fallback"},
+}
+
+func TestServeHTTPNegotiation(t *testing.T) {
+	mux := NewServeMux()
+	registerNegotiationRoutes(mux)
+
+	for _, nt := range negotiationTests {
+		r := newRequest(nt.method, nt.url)
+		if nt.accept != "" {
+			r.Header.Set("Accept", nt.accept)
+		}
+		if nt.contentType != "" {
+			r.Header.Set("Content-Type", nt.contentType)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != nt.code {
+			t.Errorf("%s %s Accept %q -> code %d, want %d", nt.method, nt.url, nt.accept, w.Code, nt.code)
+		}
+		if result := w.Header().Get("Result"); result != nt.result {
+			t.Errorf("%s %s Accept %q -> result %q, want %q", nt.method, nt.url, nt.accept, result, nt.result)
+		}
+	}
+}
+
+// compile-time assertions
+var _ qualityRule = NewAcceptRule()
+var _ qualityRule = NewContentTypeRule()
+
+// test route priorities among routes for the same pattern
+
+type headerRule string
+
+func (rule headerRule) Allows(request *http.Request) bool {
+	return request.Header.Get(string(rule)) != ""
+}
+
+func registerSamePatternRoutes(mux *ServeMux) {
+	mux.Get("/reports", stringHandler("default"))
+	mux.Get("/reports", stringHandler("beta")).Priority(2)
+	mux.Register("/reports", stringHandler("tracked"), NewMethodRule("GET"), headerRule("X-Track"))
+	mux.Register("/reports", stringHandler("debug"), NewMethodRule("GET"), headerRule("X-Debug")).Priority(1)
+	mux.Get("/exports", stringHandler("first"))
+	mux.Get("/exports", stringHandler("second"))
+	mux.Register("/exports", stringHandler("tracked"), NewMethodRule("GET"), headerRule("X-Track"))
+}
+
+var samePatternTests = []struct {
+	headers []string // request headers to set
+	url     string   // test request url
+	result  string   // expected handler result
+}{
+	// higher priority routes are preferred
+	{nil, "/reports", "beta"},
+	{[]string{"X-Track", "X-Debug"}, "/reports", "beta"},
+	// more rules are preferred among routes with equal priority
+	{nil, "/exports", "first"},
+	{[]string{"X-Track"}, "/exports", "tracked"},
+}
+
+func TestServeHTTPRoutePriority(t *testing.T) {
+	mux := NewServeMux()
+	registerSamePatternRoutes(mux)
+
+	for _, st := range samePatternTests {
+		r := newRequest("GET", st.url)
+		for _, header := range st.headers {
+			r.Header.Set(header, "1")
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if result := w.Header().Get("Result"); result != st.result {
+			t.Errorf("GET %s %v -> result %q, want %q", st.url, st.headers, result, st.result)
+		}
+	}
+}
+
+// test match rules which derive requests
+
+type tokenKey struct{}
+
+// tokenRule allows requests with a "Bearer <token>" Authorization header and
+// attaches the token to the request context.
+var tokenRule = MatchFunc(func(request *http.Request) (*http.Request, bool) {
+	token := strings.TrimPrefix(request.Header.Get("Authorization"), "Bearer ")
+	if token == "" || token == request.Header.Get("Authorization") {
+		return request, false
+	}
+	ctx := context.WithValue(request.Context(), tokenKey{}, token)
+	return request.WithContext(ctx), true
+})
+
+// tokenOwnerRule allows requests whose context token is owned by the admin.
+var tokenOwnerRule = MatchFunc(func(request *http.Request) (*http.Request, bool) {
+	token, _ := request.Context().Value(tokenKey{}).(string)
+	return request, strings.HasPrefix(token, "admin-")
+})
+
+func tokenHandler(w http.ResponseWriter, r *http.Request) {
+	token, _ := r.Context().Value(tokenKey{}).(string)
+	w.Header().Set("Result", token)
+}
+
+var matchRuleTests = []struct {
+	url           string // test request url
+	authorization string // request Authorization header
+	code          int    // expected HTTP response code
+	result        string // expected handler result
+}{
+	{"/account", "Bearer abc", 200, "abc"},
+	{"/account", "", 404, ""},
+	{"/account", "Basic abc", 404, ""},
+	// later rules are passed the derived request
+	{"/admin", "Bearer admin-xyz", 200, "admin-xyz"},
+	{"/admin", "Bearer abc", 404, ""},
+}
+
+func TestServeHTTPMatchRules(t *testing.T) {
+	mux := NewServeMux()
+	mux.Get("/account", http.HandlerFunc(tokenHandler)).Rules(tokenRule)
+	mux.Register("/admin", http.HandlerFunc(tokenHandler), tokenRule, NewMethodRule("GET"), tokenOwnerRule)
+
+	for _, mt := range matchRuleTests {
+		r := newRequest("GET", mt.url)
+		if mt.authorization != "" {
+			r.Header.Set("Authorization", mt.authorization)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != mt.code {
+			t.Errorf("GET %s %q -> code %d, want %d", mt.url, mt.authorization, w.Code, mt.code)
+		}
+		if result := w.Header().Get("Result"); result != mt.result {
+			t.Errorf("GET %s %q -> result %q, want %q", mt.url, mt.authorization, result, mt.result)
+		}
+	}
+}
@@ -152,8 +152,9 @@ var serveMuxTests = []struct {
 	{"GET", "google.com", "/dir/./file", 301, "/dir/"},
 
 	// The /foo -> /foo/ redirect applies to CONNECT requests
-	// but the path canonicalization does not.
-	{"CONNECT", "google.com", "/dir", 301, "/dir/"},
+	// but the path canonicalization does not. Non-GET redirects
+	// preserve the method.
+	{"CONNECT", "google.com", "/dir", 308, "/dir/"},
 	{"CONNECT", "google.com", "/../search", 404, ""},
 	{"CONNECT", "google.com", "/dir/..", 200, "/dir/"},
 	{"CONNECT", "google.com", "/dir/..", 200, "/dir/"},
@@ -0,0 +1,157 @@
+package warp
+
+import (
+	"context"
+	"hash/fnv"
+	"net"
+	"net/http"
+)
+
+// Variant is one of the handlers a Split pattern divides traffic between.
+// Each variant receives a share of requests proportional to its Weight.
+type Variant struct {
+	Name    string       // name reported by RequestVariant
+	Handler http.Handler // handler for requests bucketed to the variant
+	Weight  int          // relative share of requests
+}
+
+// StickyKey returns the value used to bucket a request into a Split variant,
+// or an empty string if the request has no such value. Requests with the same
+// key are always bucketed to the same variant.
+type StickyKey func(*http.Request) string
+
+// CookieKey returns a StickyKey which buckets requests by the value of the
+// named cookie.
+func CookieKey(name string) StickyKey {
+	return func(request *http.Request) string {
+		cookie, err := request.Cookie(name)
+		if err != nil {
+			return ""
+		}
+		return cookie.Value
+	}
+}
+
+// HeaderKey returns a StickyKey which buckets requests by the value of the
+// named header.
+func HeaderKey(name string) StickyKey {
+	return func(request *http.Request) string {
+		return request.Header.Get(name)
+	}
+}
+
+// ClientIPKey returns a StickyKey which buckets requests by the IP address of
+// the client, without the port.
+func ClientIPKey() StickyKey {
+	return clientIP
+}
+
+// clientIP returns the IP address of the request's remote address.
+func clientIP(request *http.Request) string {
+	host, _, err := net.SplitHostPort(request.RemoteAddr)
+	if err != nil {
+		return request.RemoteAddr
+	}
+	return host
+}
+
+// Split registers the variant handlers for the pattern, dividing requests
+// between them by weight. Requests are deterministically bucketed by the
+// hash of their sticky key, so the same user consistently reaches the same
+// variant. Requests without a key value fall back to being bucketed by client
+// IP. The chosen variant's name can be read from the request with
+// RequestVariant. Returns the new Route entries, in variant order. Split
+// panics if the key is nil, a handler is nil or no variant has a weight.
+//
+//	mux.Split("/checkout", warp.CookieKey("session"),
+//		warp.Variant{"stable", checkoutHandler, 95},
+//		warp.Variant{"canary", newCheckoutHandler, 5},
+//	)
+func (mux *ServeMux) Split(pattern string, key StickyKey, variants ...Variant) []*Route {
+	if key == nil {
+		panic("warp: split " + pattern + " has nil sticky key")
+	}
+	var total int
+	for _, variant := range variants {
+		if variant.Handler == nil {
+			panic("warp: nil handler")
+		}
+		if variant.Weight < 0 {
+			panic("warp: negative variant weight for " + variant.Name)
+		}
+		total += variant.Weight
+	}
+	if total == 0 {
+		panic("warp: split " + pattern + " has no weighted variants")
+	}
+	var routes []*Route
+	var start int
+	for _, variant := range variants {
+		rule := &weightedRule{
+			key:   key,
+			salt:  pattern,
+			start: start,
+			end:   start + variant.Weight,
+			total: total,
+		}
+		handler := &variantHandler{variant.Name, variant.Handler}
+		routes = append(routes, mux.Register(pattern, handler, rule))
+		start += variant.Weight
+	}
+	return routes
+}
+
+// weightedRule allows requests whose sticky key hashes to a bucket in
+// [start, end) out of total buckets.
+type weightedRule struct {
+	key   StickyKey
+	salt  string // keeps buckets of different splits independent
+	start int
+	end   int
+	total int
+}
+
+// Allows returns true if the request's bucket belongs to the rule's range.
+func (rule *weightedRule) Allows(request *http.Request) bool {
+	bucket := rule.bucket(request)
+	return bucket >= rule.start && bucket < rule.end
+}
+
+// bucket returns the request's bucket, between 0 and total.
+func (rule *weightedRule) bucket(request *http.Request) int {
+	key := rule.key(request)
+	if key == "" {
+		key = clientIP(request)
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(rule.salt))
+	hash.Write([]byte{0})
+	hash.Write([]byte(key))
+	return int(hash.Sum32() % uint32(rule.total))
+}
+
+// variantHandler records the name of a Split variant in the request context
+// before calling the variant's handler.
+type variantHandler struct {
+	name    string
+	handler http.Handler
+}
+
+func (h *variantHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := context.WithValue(r.Context(), variantKey, h.name)
+	h.handler.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// RequestVariant returns the name of the Split variant which is handling the
+// request, or an empty string if the request was not routed by a Split. As
+// with RequestRoute, the variant is known to Middleware, before the variant
+// handler is called.
+func RequestVariant(request *http.Request) string {
+	if route := RequestRoute(request); route != nil {
+		if handler, ok := route.handler.(*variantHandler); ok {
+			return handler.name
+		}
+	}
+	name, _ := request.Context().Value(variantKey).(string)
+	return name
+}
@@ -0,0 +1,114 @@
+package warp
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// variantRecorder writes the request's Split variant name as the Result
+func variantRecorder(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Result", RequestVariant(r))
+}
+
+func TestSplitWeights(t *testing.T) {
+	mux := NewServeMux()
+	mux.Split("/checkout", HeaderKey("X-User"),
+		Variant{"stable", http.HandlerFunc(variantRecorder), 90},
+		Variant{"canary", http.HandlerFunc(variantRecorder), 10},
+		Variant{"disabled", http.HandlerFunc(variantRecorder), 0},
+	)
+
+	counts := make(map[string]int)
+	for i := 0; i < 2000; i++ {
+		r := newRequest("GET", "/checkout")
+		r.Header.Set("X-User", fmt.Sprintf("user-%d", i))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		counts[w.Header().Get("Result")]++
+	}
+	if counts["stable"]+counts["canary"] != 2000 {
+		t.Errorf("split variants %v, want every request handled by stable or canary", counts)
+	}
+	if counts["canary"] < 100 || counts["canary"] > 300 {
+		t.Errorf("split canary handled %d of 2000 requests, want about 200", counts["canary"])
+	}
+}
+
+func TestSplitSticky(t *testing.T) {
+	mux := NewServeMux()
+	mux.Split("/checkout", CookieKey("session"),
+		Variant{"a", http.HandlerFunc(variantRecorder), 1},
+		Variant{"b", http.HandlerFunc(variantRecorder), 1},
+	)
+
+	for i := 0; i < 20; i++ {
+		session := &http.Cookie{Name: "session", Value: fmt.Sprintf("s%d", i)}
+		var first string
+		for j := 0; j < 5; j++ {
+			r := newRequest("GET", "/checkout")
+			r.AddCookie(session)
+			r.RemoteAddr = fmt.Sprintf("10.0.0.%d:1234", j)
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, r)
+			result := w.Header().Get("Result")
+			if j == 0 {
+				first = result
+			} else if result != first {
+				t.Errorf("session %s -> variant %q, previously %q", session.Value, result, first)
+			}
+		}
+	}
+}
+
+func TestSplitClientIPFallback(t *testing.T) {
+	mux := NewServeMux()
+	mux.Split("/checkout", CookieKey("session"),
+		Variant{"a", http.HandlerFunc(variantRecorder), 1},
+		Variant{"b", http.HandlerFunc(variantRecorder), 1},
+	)
+
+	rule := &weightedRule{key: ClientIPKey(), salt: "/checkout", total: 2}
+	for i := 0; i < 10; i++ {
+		r := newRequest("GET", "/checkout")
+		r.RemoteAddr = fmt.Sprintf("192.168.1.%d:5000", i)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		want := []string{"a", "b"}[rule.bucket(r)]
+		if result := w.Header().Get("Result"); result != want {
+			t.Errorf("client %s -> variant %q, want %q", r.RemoteAddr, result, want)
+		}
+	}
+}
+
+func TestSplitNilKeyPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Split with nil StickyKey did not panic")
+		}
+	}()
+	NewServeMux().Split("/checkout", nil, Variant{"stable", stringHandler("stable"), 1})
+}
+
+func TestRequestVariantMiddleware(t *testing.T) {
+	mux := NewServeMux()
+	var variants []string
+	mux.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			variants = append(variants, RequestVariant(r))
+			next.ServeHTTP(w, r)
+		})
+	})
+	mux.Split("/checkout", HeaderKey("X-User"),
+		Variant{"stable", http.HandlerFunc(variantRecorder), 1},
+	)
+	mux.Get("/cart", http.HandlerFunc(variantRecorder))
+
+	for _, path := range []string{"/checkout", "/cart"} {
+		mux.ServeHTTP(httptest.NewRecorder(), newRequest("GET", path))
+	}
+	if len(variants) != 2 || variants[0] != "stable" || variants[1] != "" {
+		t.Errorf("RequestVariant() in middleware = %q, want [stable \"\"]", variants)
+	}
+}
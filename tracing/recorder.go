@@ -0,0 +1,137 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"sync"
+)
+
+// spanKey is the context key of the span a Recorder started.
+type spanKey struct{}
+
+// Recorder is an in-memory Tracer which records the spans it starts, for
+// tests which need no trace collector.
+type Recorder struct {
+	mu    sync.Mutex
+	spans []*RecordedSpan
+}
+
+// NewRecorder returns a new Recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// Start starts a recorded span. Spans continue the trace of the parent, if
+// valid, or of the span in the context, and otherwise start a new trace.
+func (r *Recorder) Start(ctx context.Context, name string, parent SpanContext) (context.Context, Span) {
+	if !parent.IsValid() {
+		if span, ok := ctx.Value(spanKey{}).(*RecordedSpan); ok {
+			parent = span.SpanContext()
+		}
+	}
+	span := &RecordedSpan{
+		name:       name,
+		parent:     parent,
+		attributes: make(map[string]interface{}),
+	}
+	span.context.TraceID = parent.TraceID
+	span.context.Sampled = true
+	span.context.TraceState = parent.TraceState
+	if !parent.IsValid() {
+		rand.Read(span.context.TraceID[:])
+	}
+	rand.Read(span.context.SpanID[:])
+
+	r.mu.Lock()
+	r.spans = append(r.spans, span)
+	r.mu.Unlock()
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// SpanFromContext returns the recorded span in the context, or nil.
+func (r *Recorder) SpanFromContext(ctx context.Context) Span {
+	if span, ok := ctx.Value(spanKey{}).(*RecordedSpan); ok {
+		return span
+	}
+	return nil
+}
+
+// Spans returns the spans started so far, in start order.
+func (r *Recorder) Spans() []*RecordedSpan {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*RecordedSpan(nil), r.spans...)
+}
+
+// Reset forgets the recorded spans.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = nil
+}
+
+// RecordedSpan is a Span started by a Recorder.
+type RecordedSpan struct {
+	mu         sync.Mutex
+	name       string
+	context    SpanContext
+	parent     SpanContext
+	attributes map[string]interface{}
+	ended      bool
+}
+
+// SpanContext returns the span context of the span.
+func (s *RecordedSpan) SpanContext() SpanContext {
+	return s.context
+}
+
+// SetName renames the span.
+func (s *RecordedSpan) SetName(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.name = name
+}
+
+// SetAttributes sets the attributes on the span, replacing any previous
+// values.
+func (s *RecordedSpan) SetAttributes(attributes ...Attribute) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, attribute := range attributes {
+		s.attributes[attribute.Key] = attribute.Value
+	}
+}
+
+// End ends the span.
+func (s *RecordedSpan) End() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ended = true
+}
+
+// Name returns the name of the span.
+func (s *RecordedSpan) Name() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.name
+}
+
+// Parent returns the span context of the parent span, which is invalid if
+// the span started a new trace.
+func (s *RecordedSpan) Parent() SpanContext {
+	return s.parent
+}
+
+// Attribute returns the value of the span attribute, or nil.
+func (s *RecordedSpan) Attribute(key string) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.attributes[key]
+}
+
+// Ended returns true if the span has ended.
+func (s *RecordedSpan) Ended() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ended
+}
@@ -0,0 +1,162 @@
+// Package tracing provides per-route tracing middleware for a
+// warp.ServeMux, compatible with OpenTelemetry. Each request is recorded by
+// a span named by its method and matched route pattern (e.g.
+// "GET /notes/:id"), with route attributes, continuing any W3C Trace Context
+// propagated in the request headers.
+//
+// Spans are started by a Tracer, which may adapt a tracing library such as
+// OpenTelemetry, or the in-memory Recorder for tests:
+//
+//	recorder := tracing.NewRecorder()
+//	mux.Use(tracing.Middleware(recorder))
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/dghubble/warp"
+	"github.com/dghubble/warp/internal/response"
+)
+
+// Span attribute keys recorded by Middleware.
+const (
+	RouteKey       = "http.route"                // matched route pattern
+	MethodKey      = "http.request.method"       // request method
+	StatusCodeKey  = "http.response.status_code" // response status
+	ParamsCountKey = "warp.params.count"         // num params captured by the route
+	RedirectKey    = "warp.redirect"             // whether ServeMux generated a redirect
+)
+
+// Attribute is a key value pair describing a span.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// SpanContext identifies a span, as propagated by W3C Trace Context headers.
+type SpanContext struct {
+	TraceID    [16]byte
+	SpanID     [8]byte
+	Sampled    bool
+	TraceState string // vendor specific trace state, passed along unchanged
+}
+
+// IsValid returns true if the span context has non-zero trace and span IDs.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// Span records a unit of work.
+type Span interface {
+	SpanContext() SpanContext
+	SetName(name string)
+	SetAttributes(attributes ...Attribute)
+	End()
+}
+
+// Tracer starts spans.
+type Tracer interface {
+	// Start starts a span, as a child of the remote parent if it is valid,
+	// and returns a context containing the span.
+	Start(ctx context.Context, name string, parent SpanContext) (context.Context, Span)
+	// SpanFromContext returns the span already recording the request in the
+	// context, such as a span started by outer middleware, or nil.
+	SpanFromContext(ctx context.Context) Span
+}
+
+// Middleware returns warp.Middleware which records each request dispatched
+// by a mux with a span named by its method and route pattern, or only its
+// method if no route handled the request, as for redirects and 404s. If the request
+// context already has a span, the span is renamed and annotated instead, and
+// left for its owner to end. Otherwise, a span is started as a child of the
+// span context propagated in the request headers, if any.
+//
+//	mux.Use(tracing.Middleware(tracer))
+func Middleware(tracer Tracer) warp.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			name := r.Method
+			attributes := []Attribute{
+				{MethodKey, r.Method},
+				{ParamsCountKey, len(warp.RequestParams(r))},
+				{RedirectKey, warp.RequestRedirect(r)},
+			}
+			// redirects are not dispatched to a route, as in metrics, and are
+			// annotated by RedirectKey instead
+			if route := warp.RequestRoute(r); route != nil {
+				name += " " + route.Pattern()
+				attributes = append(attributes, Attribute{RouteKey, route.Pattern()})
+			}
+
+			span := tracer.SpanFromContext(r.Context())
+			if span != nil {
+				span.SetName(name)
+			} else {
+				parent, _ := Extract(r.Header)
+				var ctx context.Context
+				ctx, span = tracer.Start(r.Context(), name, parent)
+				defer span.End()
+				r = r.WithContext(ctx)
+			}
+			span.SetAttributes(attributes...)
+
+			recorder := response.NewRecorder(w)
+			next.ServeHTTP(recorder, r)
+			span.SetAttributes(Attribute{StatusCodeKey, recorder.Status()})
+		})
+	}
+}
+
+// Extract returns the span context propagated in the W3C traceparent and
+// tracestate headers, and whether a valid traceparent was found.
+// See https://www.w3.org/TR/trace-context/
+func Extract(header http.Header) (SpanContext, bool) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(header.Get("traceparent")), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || parts[0] == "00" && len(parts) != 4 {
+		return sc, false
+	}
+	version, err := hex.DecodeString(parts[0])
+	if err != nil || len(version) != 1 {
+		return sc, false
+	}
+	if !decodeHex(sc.TraceID[:], parts[1]) || !decodeHex(sc.SpanID[:], parts[2]) {
+		return sc, false
+	}
+	var flags [1]byte
+	if !decodeHex(flags[:], parts[3]) || !sc.IsValid() {
+		return SpanContext{}, false
+	}
+	sc.Sampled = flags[0]&1 == 1
+	sc.TraceState = strings.Join(header.Values("tracestate"), ",")
+	return sc, true
+}
+
+// Inject sets the W3C traceparent and tracestate headers propagating the
+// span context, for outgoing requests.
+func Inject(sc SpanContext, header http.Header) {
+	if !sc.IsValid() {
+		return
+	}
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	header.Set("traceparent", "00-"+hex.EncodeToString(sc.TraceID[:])+"-"+hex.EncodeToString(sc.SpanID[:])+"-"+flags)
+	if sc.TraceState != "" {
+		header.Set("tracestate", sc.TraceState)
+	}
+}
+
+// decodeHex decodes the lowercase hex string into dst, which it must fill
+// exactly.
+func decodeHex(dst []byte, s string) bool {
+	if len(s) != 2*len(dst) || strings.ToLower(s) != s {
+		return false
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err == nil
+}
@@ -0,0 +1,167 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dghubble/warp"
+)
+
+const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestMiddleware(t *testing.T) {
+	recorder := NewRecorder()
+	mux := warp.NewServeMux()
+	mux.Use(Middleware(recorder))
+	mux.Get("/notes/:id", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if span := recorder.SpanFromContext(r.Context()); span == nil {
+			t.Errorf("handler context has no span")
+		}
+	}))
+	mux.Get("/docs/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	cases := []struct {
+		path     string
+		name     string
+		route    interface{}
+		params   int
+		redirect bool
+		status   int
+	}{
+		{"/notes/1", "GET /notes/:id", "/notes/:id", 1, false, 200},
+		{"/docs", "GET", nil, 0, true, 301},
+		{"/missing", "GET", nil, 0, false, 404},
+	}
+	for _, c := range cases {
+		recorder.Reset()
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", c.path, nil))
+		spans := recorder.Spans()
+		if len(spans) != 1 {
+			t.Fatalf("%s recorded %d spans, want 1", c.path, len(spans))
+		}
+		span := spans[0]
+		if span.Name() != c.name {
+			t.Errorf("%s span named %q, want %q", c.path, span.Name(), c.name)
+		}
+		if !span.Ended() {
+			t.Errorf("%s span not ended", c.path)
+		}
+		if span.Parent().IsValid() {
+			t.Errorf("%s span has parent %v, want new trace", c.path, span.Parent())
+		}
+		attributes := map[string]interface{}{
+			MethodKey:      "GET",
+			RouteKey:       c.route,
+			ParamsCountKey: c.params,
+			RedirectKey:    c.redirect,
+			StatusCodeKey:  c.status,
+		}
+		for key, want := range attributes {
+			if got := span.Attribute(key); got != want {
+				t.Errorf("%s span attribute %s = %v, want %v", c.path, key, got, want)
+			}
+		}
+	}
+}
+
+func TestMiddlewarePropagation(t *testing.T) {
+	recorder := NewRecorder()
+	mux := warp.NewServeMux()
+	mux.Use(Middleware(recorder))
+	mux.Get("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("traceparent", traceparent)
+	req.Header.Set("tracestate", "vendor=value")
+	mux.ServeHTTP(httptest.NewRecorder(), req)
+
+	span := recorder.Spans()[0]
+	parent, _ := Extract(req.Header)
+	if span.Parent() != parent {
+		t.Errorf("span parent %v, want %v", span.Parent(), parent)
+	}
+	sc := span.SpanContext()
+	if sc.TraceID != parent.TraceID || sc.SpanID == parent.SpanID || sc.TraceState != "vendor=value" {
+		t.Errorf("span context %v does not continue trace %v", sc, parent)
+	}
+}
+
+func TestMiddlewareAnnotatesSpan(t *testing.T) {
+	recorder := NewRecorder()
+	mux := warp.NewServeMux()
+	mux.Use(Middleware(recorder))
+	mux.Get("/notes/:id", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+
+	// span started by outer middleware, such as a server instrumentation
+	ctx, outer := recorder.Start(context.Background(), "HTTP GET", SpanContext{})
+	req := httptest.NewRequest("GET", "/notes/1", nil).WithContext(ctx)
+	mux.ServeHTTP(httptest.NewRecorder(), req)
+
+	if spans := recorder.Spans(); len(spans) != 1 {
+		t.Fatalf("recorded %d spans, want only the outer span", len(spans))
+	}
+	span := outer.(*RecordedSpan)
+	if span.Name() != "GET /notes/:id" {
+		t.Errorf("span named %q, want %q", span.Name(), "GET /notes/:id")
+	}
+	if span.Ended() {
+		t.Errorf("outer span ended by middleware")
+	}
+	if status := span.Attribute(StatusCodeKey); status != http.StatusAccepted {
+		t.Errorf("span status %v, want %d", status, http.StatusAccepted)
+	}
+}
+
+func TestExtract(t *testing.T) {
+	cases := []struct {
+		traceparent string
+		valid       bool
+		sampled     bool
+	}{
+		{traceparent, true, true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", true, false},
+		// future versions may append fields
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", true, true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false, false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false, false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false, false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false, false},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false, false},
+		{"00-4bf92f3577b34da6-00f067aa0ba902b7-01", false, false},
+		{"", false, false},
+	}
+	for _, c := range cases {
+		header := http.Header{}
+		header.Set("traceparent", c.traceparent)
+		sc, ok := Extract(header)
+		if ok != c.valid || sc.Sampled != c.sampled {
+			t.Errorf("Extract(%q) = sampled %v, %v, want %v, %v", c.traceparent, sc.Sampled, ok, c.sampled, c.valid)
+		}
+	}
+}
+
+func TestInject(t *testing.T) {
+	in := http.Header{}
+	in.Set("traceparent", traceparent)
+	in.Set("tracestate", "vendor=value")
+	sc, _ := Extract(in)
+
+	out := http.Header{}
+	Inject(sc, out)
+	if got := out.Get("traceparent"); got != traceparent {
+		t.Errorf("Inject() traceparent %q, want %q", got, traceparent)
+	}
+	if got := out.Get("tracestate"); got != "vendor=value" {
+		t.Errorf("Inject() tracestate %q, want %q", got, "vendor=value")
+	}
+
+	out = http.Header{}
+	Inject(SpanContext{}, out)
+	if len(out) != 0 {
+		t.Errorf("Inject() invalid span context set headers %v", out)
+	}
+}
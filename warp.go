@@ -1,11 +1,13 @@
 package warp
 
 import (
+	"context"
 	"net/http"
 	"net/url"
 	"path"
 	"strings"
 	"sync"
+	"unicode"
 )
 
 // ServeMux is an HTTP request multiplexer.
@@ -30,21 +32,116 @@ import (
 // URLs on that host only.  Host-specific patterns take precedence over
 // general patterns, so that a handler might register for the two patterns
 // "/codesearch" and "codesearch.google.com/" without also taking over
-// requests for "http://www.google.com/".
+// requests for "http://www.google.com/". Host names are matched
+// case-insensitively, in their ASCII (punycode) form, and ignore the request
+// port unless the pattern names one. Host labels may be captured as params,
+// as in "{tenant}.example.com/", or matched by a "*" wildcard, as in
+// "*.example.com/".
+//
+// Path patterns may capture several params from one segment, as in
+// "/files/:name.:ext", where each capture ends at the first occurrence of
+// the literal rune following it (so "/files/archive.tar.gz" captures name
+// "archive" and ext "tar.gz"). Parenthesized groups are optional, so
+// "/reports/:year(/:month)" matches both "/reports/2024" and
+// "/reports/2024/05". A path matching a pattern both with and without its
+// optional groups matches the expansion with the most literal runes, and
+// patterns without optional groups are preferred over patterns with them.
+// Literal parens are escaped with a backslash, as in `/wiki/Go_\(lang\)`,
+// and a literal backslash preceding a paren as `\\`. Unescaped parens in
+// patterns registered before optional groups were supported now begin
+// groups, so such patterns must be escaped.
+//
+// A param name may be repeated, as in "/compare/:id/:id", to capture several
+// values in order. A final "*name" segment, as in "/tags/*tags", captures
+// each of the one or more remaining path segments as a value of the name,
+// and is preferred less than a single-segment capture of the same path.
+// Handlers read all captured values with ParamValues. A literal '*' starting
+// the final segment is escaped with a backslash, as in `/search/\*terms`.
+//
+// Routes may serve several formats of a resource, as in
+// mux.Get("/reports/:id", h).Formats("json", "csv"), in which case
+// "/reports/42.csv" matches the pattern with its extension stripped and
+// "/reports/42" negotiates its format from the Accept header. Patterns with
+// literal extensions, as in "/reports/latest.json", take precedence.
 //
 // ServeMux also takes care of sanitizing the URL request path,
 // redirecting any request containing . or .. elements to an
 // equivalent .- and ..-free URL.
 type ServeMux struct {
+	// TrailingSlash is the policy for paths which differ from a pattern only
+	// by a trailing slash. The zero value, RedirectToSlash, redirects /tree
+	// to /tree/ as http.ServeMux does.
+	TrailingSlash TrailingSlash
+
+	// RedirectCode is the status code of the redirects ServeMux generates,
+	// to clean paths and trailing slash twins, for GET and HEAD requests.
+	// Defaults to 301 Moved Permanently if zero.
+	RedirectCode int
+
+	// MethodRedirectCode is the status code of the redirects ServeMux
+	// generates for requests with other methods, which clients should repeat
+	// with the same method and body. Defaults to 308 Permanent Redirect if
+	// zero, 307 Temporary Redirect may be used instead.
+	MethodRedirectCode int
+
+	// CaseInsensitive enables matching the literal parts of path patterns
+	// case-insensitively, under Unicode case folding, so /Notes/42 matches
+	// /notes/:id. Captured param values are passed to handlers unchanged.
+	CaseInsensitive bool
+
+	// CanonicalRedirect, when CaseInsensitive is enabled, redirects paths
+	// matching a pattern with different casing to the pattern's canonical
+	// casing, instead of dispatching them directly.
+	CanonicalRedirect bool
+
+	// UseEscapedPath enables matching patterns against the escaped request
+	// path, URL.EscapedPath(), rather than the decoded URL.Path, so an
+	// encoded slash ("%2F") is part of its segment and never separates
+	// segments. Each captured param value is unescaped individually.
+	UseEscapedPath bool
+
+	// ErrorHandler responds to errors passed to ServeError, such as panics
+	// recovered by Recover, for routes without their own ErrorHandler.
+	// Defaults to ProblemErrorHandler if nil.
+	ErrorHandler ErrorHandler
+
 	mu       sync.RWMutex
 	routes   map[string][]*Route // pattern -> routes
+	escaped  map[string]string   // pattern -> pattern with escaped literals
 	anyHosts bool                // whether any patterns contain hostnames
+	// path pattern -> expansions of its optional groups and escapes
+	expansions map[string][]string
+	// middleware wrapping dispatched handlers, outermost first
+	middleware []Middleware
 }
 
+// TrailingSlash is a policy for handling request paths which match a
+// registered pattern only once a trailing slash is added or removed.
+type TrailingSlash int
+
+const (
+	// RedirectToSlash permanently redirects /tree to /tree/ when the /tree/
+	// pattern is registered, but not /tree.
+	RedirectToSlash TrailingSlash = iota
+	// StrictSlash requires paths to match patterns exactly as registered, so
+	// /tree and /leaf/ are not found unless registered.
+	StrictSlash
+	// RedirectToNoSlash permanently redirects /leaf/ to /leaf when the /leaf
+	// pattern is registered, but not /leaf/. /tree is not found unless
+	// registered.
+	RedirectToNoSlash
+	// IgnoreSlash dispatches /tree to the /tree/ routes and /leaf/ to the
+	// /leaf routes directly, when the path does not match otherwise. Unlike
+	// redirects, the routes' rules must allow the request.
+	IgnoreSlash
+)
+
 // NewServeMux allocates and returns a new *ServeMux.
 func NewServeMux() *ServeMux {
 	return &ServeMux{
-		routes: make(map[string][]*Route),
+		routes:     make(map[string][]*Route),
+		escaped:    make(map[string]string),
+		expansions: make(map[string][]string),
 	}
 }
 
@@ -114,15 +211,17 @@ func (mux *ServeMux) Options(pattern string, handler http.Handler) *Route {
 // the pattern that will match after following the redirect.
 //
 // If there is no registered handler that applies to the request,
-// Handler returns a ``page not found'' handler and an empty pattern.
+// Handler returns a “page not found” handler and an empty pattern.
 func (mux *ServeMux) Handler(request *http.Request) (handler http.Handler, pattern string) {
-	handler, pattern, _ = mux.reqHandler(request)
-	return handler, pattern
+	d := mux.reqHandler(request, nil)
+	return d.handler, d.pattern
 }
 
 // ServeHTTP matches the request to the route whose pattern most closely
 // matches the URL, encodes captured params in the request RawQuery, and
-// dispatches the request to the matched handler.
+// dispatches the request, as derived by any MatchRules of the route, to the
+// matched handler, wrapped by any Middleware. The matched Route and params
+// can be read from the request with RequestRoute and RequestParams.
 func (mux *ServeMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if r.RequestURI == "*" {
 		if r.ProtoAtLeast(1, 1) {
@@ -131,151 +230,455 @@ func (mux *ServeMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	handler, _, params := mux.reqHandler(r)
+	d := mux.reqHandler(r, nil)
+	d.mux = mux
+	r = d.request.WithContext(context.WithValue(d.request.Context(), dispatchKey, d))
 	// add capture params to query params
-	if len(params) > 0 {
-		r.URL.RawQuery = url.Values(params).Encode() + "&" + r.URL.RawQuery
+	if len(d.params) > 0 {
+		r.URL.RawQuery = url.Values(d.params).Encode() + "&" + r.URL.RawQuery
+	}
+	mux.mu.RLock()
+	middleware := mux.middleware
+	mux.mu.RUnlock()
+	handler := d.handler
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
 	}
 	handler.ServeHTTP(w, r)
 }
 
-// addRoute registers the pattern for the handler for requests with the given
-// HTTP method. If the pattern is a /tree/, inserts an implicit permanent
-// redirect for /tree to /tree/ (provided no implicit /tree route exists). If
-// the pattern is empty or the handler is nil, add panics.
+// Middleware wraps a handler with behavior, such as logging or metrics, which
+// runs around it.
+type Middleware func(http.Handler) http.Handler
+
+// Use adds middleware wrapping the handlers ServeMux dispatches requests to,
+// including redirects, rejections and NotFound handlers. Middleware runs
+// after matching, so it can read the matched Route with RequestRoute, and
+// wraps handlers in the order added, the first added being outermost.
+func (mux *ServeMux) Use(middleware ...Middleware) {
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+	mux.middleware = append(mux.middleware[:len(mux.middleware):len(mux.middleware)], middleware...)
+}
+
+// addRoute registers the route for the pattern. Paths differing from the
+// pattern by a trailing slash are handled at match time, according to the
+// TrailingSlash policy. If the pattern is empty or the handler is nil, add
+// panics.
 func (mux *ServeMux) addRoute(pattern string, route *Route) {
 	mux.mu.Lock()
 	defer mux.mu.Unlock()
 
-	if route.pattern == "" {
+	if route.pattern == "" || !validOptional(pattern) || !validSplat(pattern) {
 		panic("warp: invalid pattern " + pattern)
 	}
 	if route.handler == nil {
 		panic("warp: nil handler")
 	}
-	mux.routes[pattern] = append(mux.routes[pattern], route)
+	// pattern keys have normalized hosts, the route reports the pattern as
+	// registered
+	key := pattern
+	host, path := splitPattern(pattern)
+	if host != "" {
+		key = normalizeHostPattern(host) + path
+	}
+	mux.routes[key] = append(mux.routes[key], route)
+	if _, ok := mux.escaped[key]; !ok {
+		_, escapedPath := splitPattern(key)
+		mux.escaped[key] = key[:len(key)-len(escapedPath)] + escapePattern(escapedPath)
+	}
+	addExpansions(mux.expansions, key, mux.escaped[key])
 
 	// if registering the first pattern with a hostname
-	if !mux.anyHosts && len(pattern) > 0 && pattern[0] != '/' {
+	if !mux.anyHosts && host != "" {
 		mux.anyHosts = true
 	}
+}
 
-	// check if pattern is a /tree/ and no implicit route exists for the pattern
-	// and insert a /tree -> /tree/ permanent redirect. If the pattern contains
-	// a hostname, it is stripped from the redirection target url. Note that the
-	// pattern key is /tree, but the route pattern is /tree/ for compliance with
-	// http.ServeMux.Handler behavior and tests.
-	n := len(pattern)
-	if n > 1 && pattern[n-1] == '/' && !mux.hasImplicitRoute(pattern[:n-1]) {
-		target := pattern
-		// if pattern has a hostname, strip it from the target
-		if pattern[0] != '/' {
-			target = pattern[strings.Index(pattern, "/"):]
+// redirectCode returns the status code for redirects of requests with the
+// given method. Redirects of methods other than GET and HEAD preserve the
+// method and body.
+func (mux *ServeMux) redirectCode(method string) int {
+	if method == "GET" || method == "HEAD" {
+		if mux.RedirectCode == 0 {
+			return http.StatusMovedPermanently
 		}
-		route := &Route{pattern, http.RedirectHandler(target, http.StatusMovedPermanently), true, nil}
-		mux.routes[pattern[:n-1]] = append(mux.routes[pattern[:n-1]], route)
+		return mux.RedirectCode
+	}
+	if mux.MethodRedirectCode == 0 {
+		return http.StatusPermanentRedirect
 	}
+	return mux.MethodRedirectCode
 }
 
-// hasImplicitRoute returns true if the pattern has an implicit route (i.e.
-// added by ServeMux), false otherwise.
-func (mux *ServeMux) hasImplicitRoute(pattern string) bool {
-	for _, route := range mux.routes[pattern] {
-		if route.implicit {
-			return true
-		}
+// pathRedirect returns a handler which redirects the request to the given
+// path, such as its trailing slash twin or canonical path. The target is
+// relative to the request host and scheme and keeps the request query string.
+func (mux *ServeMux) pathRedirect(request *http.Request, path string) http.Handler {
+	target := &url.URL{Path: path, RawQuery: request.URL.RawQuery}
+	if mux.UseEscapedPath {
+		target.Path, target.RawPath = unescapePath(path), path
 	}
-	return false
+	return http.RedirectHandler(target.String(), mux.redirectCode(request.Method))
+}
+
+// dispatch is the outcome of matching a request to a handler.
+type dispatch struct {
+	handler  http.Handler  // handler for the request
+	pattern  string        // pattern to report, empty if no route matched
+	params   url.Values    // params captured from the host and path
+	request  *http.Request // request derived by route rules, or the original
+	route    *Route        // route whose handler serves the request, or nil
+	redirect bool          // true if the handler redirects to a canonical path
+	mux      *ServeMux     // mux which dispatched the request
 }
 
 // reqHandler matches the, possibly unclean, request URL path to the closest
-// route and returns the matched handler, pattern, and captured params. For
-// unclean paths, the returned handler is a redirect handler to the closes
-// matching patter. Matching clean paths is delegated to handler.
-func (mux *ServeMux) reqHandler(req *http.Request) (http.Handler, string, url.Values) {
+// route and returns the matched handler, pattern, captured params and
+// request. For unclean paths, the returned handler is a redirect handler to
+// the closes matching patter. Matching clean paths is delegated to handler.
+func (mux *ServeMux) reqHandler(req *http.Request, trace *Explanation) *dispatch {
+	path := req.URL.Path
+	if mux.UseEscapedPath {
+		path = escapePath(req.URL.EscapedPath())
+	}
 	if req.Method != "CONNECT" {
-		if cleanedPath := cleanPath(req.URL.Path); cleanedPath != req.URL.Path {
+		if cleanedPath := cleanPath(path); cleanedPath != path {
 			url := *req.URL
 			url.Path = cleanedPath
-			_, pattern, _ := mux.handler(req, cleanedPath)
-			return http.RedirectHandler(url.String(), http.StatusMovedPermanently), pattern, nil
+			if mux.UseEscapedPath {
+				url.Path, url.RawPath = unescapePath(cleanedPath), cleanedPath
+			}
+			d := mux.handler(req, cleanedPath, trace)
+			return &dispatch{
+				handler:  http.RedirectHandler(url.String(), mux.redirectCode(req.Method)),
+				pattern:  d.pattern,
+				request:  req,
+				redirect: true,
+			}
 		}
 	}
-	return mux.handler(req, req.URL.Path)
+	return mux.handler(req, path, trace)
 }
 
 // handler matches the given path to the route with the closest matching
-// pattern and returns the handler, pattern, and captured params. Returns
-// a NotFoundHandler, empty string pattern, and nil params if no route
-// matches. The given path is assumed to be the canonical (cleaned)
-// request.URL.Path, except for CONNECT methods. host-specific patterns
-// are preferred over generic path patterns.
-func (mux *ServeMux) handler(request *http.Request, path string) (handler http.Handler, pattern string, params url.Values) {
+// pattern and returns the handler, pattern, captured params, and request
+// derived by the route's rules. Returns a NotFoundHandler, empty string
+// pattern, and nil params if no route matches. If routes matched the path
+// but their rules rejected the request, the handler is the Rejection of the
+// closest route's rejecting rule (e.g. 406 Not Acceptable) instead.
+// The given path is assumed to be the canonical (cleaned) request.URL.Path,
+// except for CONNECT methods. host-specific patterns are preferred over
+// generic path patterns.
+func (mux *ServeMux) handler(request *http.Request, path string, trace *Explanation) *dispatch {
 	mux.mu.RLock()
 	defer mux.mu.RUnlock()
 
+	var best candidate
+	var rejected *Rejection
 	// host-specific patterns
-	if mux.anyHosts {
-		handler, pattern, params = mux.match(request, request.Host+path)
+	if host := requestHost(request); mux.anyHosts && host != "" {
+		best, rejected = mux.match(request, host, path, trace)
 	}
 	// generic patterns
-	if handler == nil {
-		handler, pattern, params = mux.match(request, path)
+	if best.route == nil {
+		var genericRejected *Rejection
+		best, genericRejected = mux.match(request, "", path, trace)
+		if rejected == nil {
+			rejected = genericRejected
+		}
+	}
+	if mux.UseEscapedPath {
+		best.params = unescapeParams(best.params)
+	}
+	if best.route != nil && mux.CaseInsensitive && mux.CanonicalRedirect {
+		_, pathPattern := splitPattern(best.route.pattern)
+		if mux.UseEscapedPath {
+			pathPattern = escapePattern(pathPattern)
+		}
+		// canonicalize using the optional group expansion which matched
+		matched := path
+		if best.twin {
+			matched = toggleSlash(path)
+		}
+		if best.extension != "" {
+			matched, _ = splitFormat(path)
+		}
+		_, _, _, expanded := optionalPathMatch(pathPattern, mux.expansions[pathPattern], matched, true)
+		if canonical := canonicalPath(expanded, path); canonical != path {
+			if best.twin && mux.TrailingSlash != IgnoreSlash {
+				canonical = toggleSlash(canonical)
+			}
+			return &dispatch{
+				handler:  mux.pathRedirect(request, canonical),
+				pattern:  best.route.pattern,
+				params:   best.params,
+				request:  request,
+				redirect: true,
+			}
+		}
+	}
+	if best.twin && mux.TrailingSlash != IgnoreSlash {
+		return &dispatch{
+			handler:  mux.pathRedirect(request, toggleSlash(path)),
+			pattern:  best.route.pattern,
+			params:   best.params,
+			request:  request,
+			redirect: true,
+		}
+	}
+	if best.route != nil {
+		return &dispatch{
+			handler: best.route.handler,
+			pattern: best.route.pattern,
+			params:  best.params,
+			request: best.request,
+			route:   best.route,
+		}
 	}
 	// no handler found
-	if handler == nil {
-		handler, pattern = http.NotFoundHandler(), ""
+	if rejected != nil {
+		return &dispatch{handler: rejected, request: request}
 	}
-	return handler, pattern, params
+	return &dispatch{handler: http.NotFoundHandler(), request: request}
 }
 
 // match will find the route that most closely matches the request. It first
-// checks the request path against registered patterns for different route
-// sets. Then, for routes matching the pattern, it checks that the request
-// matches the route rules. In decreasing importance, longer patterns (more
-// specific), explicit routes, and more capture params are preferred.
+// checks the request host and path against registered patterns for different
+// route sets. If host is empty, only generic patterns are considered,
+// otherwise only host-specific patterns are considered. Paths which match a
+// pattern only once a trailing slash is added or removed match the pattern's
+// implicit twin, if the TrailingSlash policy allows. Then, for routes
+// matching the pattern, it checks that the request matches the route rules.
+// Routes with Formats also match the path with its format extension
+// stripped. In decreasing importance, longer patterns (more specific),
+// explicit routes, patterns without optional groups, and more capture params
+// are preferred. Among routes for the same pattern, routes with a higher
+// Priority, then a higher content negotiation quality, then more rules are
+// preferred, and otherwise the first registered route.
 // Examples:
 // Path /foo/bar/ matches /foo/bar/ over /foo/
 // Path /explicit matches registered /explicit route over an implicit /explicit
 // -> /explicit/ redirect from registering /explicit/
 // Path /notes/new matches /notes/new over /notes/:id
 // Path /site/i matches /site/:name over /site/
-func (mux *ServeMux) match(request *http.Request, path string) (handler http.Handler, reportPattern string, params url.Values) {
-	var n = 0 // num runes matched in best match pattern
-	var l = 0 // length of best match pattern
+// Path /reports/2024/05 matches /reports/:year/:month over
+// /reports/:year(/:month), which matches /reports/2024 as well
+// If no route allows the request, match returns the Rejection from the rule
+// that rejected the request on the route which matched the most runes and
+// passed the most rules.
+func (mux *ServeMux) match(request *http.Request, host, path string, trace *Explanation) (candidate, *Rejection) {
+	var best candidate
+	var rejected Rule
+	var rejectedRequest *http.Request // request as seen by the rejected rule
+	var rn = 0                        // num runes matched by the closest rejected route
+	var passed = 0                    // num rules passed by the closest rejected route
+	reject := func(runeCount, i int, rule Rule, request *http.Request) {
+		if rejected == nil || runeCount > rn || runeCount == rn && i > passed {
+			rn, passed, rejected, rejectedRequest = runeCount, i, rule, request
+		}
+	}
+	first := trace.size()
+	stripped, extension := splitFormat(path)
 	for pattern, routes := range mux.routes {
-		// skip patterns that the path doesn't match
-		isMatch, runeCount, parameters := pathMatch(pattern, path)
+		if mux.UseEscapedPath {
+			pattern = mux.escaped[pattern]
+		}
+		// skip patterns that the host and path (or its twin) don't match
+		var twin bool
+		var length = splatLength(pattern)
+		isMatch, runeCount, params := mux.patternMatch(pattern, host, path)
 		if !isMatch {
+			twin = true
+			isMatch, runeCount, params, length = mux.twinMatch(pattern, host, path)
+		}
+		// routes with Formats may match the path stripped of its extension
+		var formatMatch bool
+		var formatCount int
+		var formatParams url.Values
+		if extension != "" && hasFormat(routes, extension) {
+			formatMatch, formatCount, formatParams = mux.patternMatch(pattern, host, stripped)
+		}
+		if !isMatch && !formatMatch {
+			for _, route := range routes {
+				trace.unmatched(route)
+			}
 			continue
 		}
+		// trailing slash redirects do not enforce the rules of the routes
+		var redirect bool
+		if isMatch && twin && mux.TrailingSlash != IgnoreSlash {
+			c := candidate{
+				route:     routes[0],
+				params:    params,
+				request:   request,
+				runeCount: runeCount,
+				length:    length,
+				optional:  hasOptional(pattern),
+				twin:      true,
+			}
+			trace.twin(c)
+			if c.beats(&best) {
+				best = c
+			}
+			isMatch, redirect = false, true
+		}
 		for _, route := range routes {
+			routeRequest, routeCount, routeParams, routeTwin := request, runeCount, params, twin
+			var routeExtension string
+			switch {
+			case formatMatch && contains(route.formats, extension):
+				routeRequest = withFormat(request, extension)
+				routeCount, routeParams, routeTwin = formatCount, formatParams, false
+				routeExtension = extension
+			case !isMatch:
+				// routes matched through their twin are traced as the twin
+				if !redirect {
+					trace.unmatched(route)
+				}
+				continue
+			case len(route.formats) > 0:
+				format, rule := route.negotiateFormat(request)
+				if rule != nil {
+					trace.evaluated(candidate{route: route, runeCount: runeCount}, nil, 0, rule)
+					reject(runeCount, 0, rule, request)
+					continue
+				}
+				routeRequest = withFormat(request, format)
+			}
 			// skip routes with rules that don't allow the request
-			if !route.Allows(request) {
+			derived, i, rule := route.reject(routeRequest)
+			c := candidate{
+				route:     route,
+				params:    routeParams,
+				request:   derived,
+				runeCount: routeCount,
+				length:    length,
+				optional:  hasOptional(pattern),
+				twin:      routeTwin,
+				extension: routeExtension,
+			}
+			if rule != nil {
+				trace.evaluated(c, route.rules, i, rule)
+				reject(routeCount, i, rule, derived)
 				continue
 			}
-			// prefer longer patterns
-			if handler == nil || runeCount > n {
-				n = runeCount
-				handler = route.handler
-				// redirect route's pattern differs from pattern key
-				reportPattern = route.pattern
-				params = parameters
-				l = len(pattern)
+			c.quality = route.quality(derived)
+			trace.evaluated(c, route.rules, i, nil)
+			if c.beats(&best) {
+				best = c
 			}
+		}
+	}
+	trace.choose(&best, first)
+	if best.route != nil || rejected == nil {
+		return best, nil
+	}
+	return best, rejectionOf(rejected, rejectedRequest)
+}
 
-			if runeCount == n {
-				// prefer explicit routes that are longer , longer patterns excluding param names
-				if !route.implicit && len(pattern) >= l {
-					handler = route.handler
-					reportPattern = route.pattern
-					params = parameters
-					l = len(pattern)
-				}
-			}
+// twinMatch returns whether the host and path match the trailing slash twin
+// of the pattern allowed by the TrailingSlash policy, along with the runes
+// matched, params captured and length of the twin pattern. The twin of a
+// /tree/ pattern is the /tree leaf and the twin of a /leaf pattern is /leaf/,
+// matching only that path.
+func (mux *ServeMux) twinMatch(pattern, host, path string) (bool, int, url.Values, int) {
+	n := len(pattern)
+	if pattern[n-1] == '/' {
+		if n < 2 || mux.TrailingSlash != RedirectToSlash && mux.TrailingSlash != IgnoreSlash {
+			return false, 0, nil, 0
 		}
+		isMatch, runeCount, params := mux.patternMatch(pattern[:n-1], host, path)
+		return isMatch, runeCount, params, n - 1
+	}
+	m := len(path)
+	if m < 2 || path[m-1] != '/' || mux.TrailingSlash != RedirectToNoSlash && mux.TrailingSlash != IgnoreSlash {
+		return false, 0, nil, 0
+	}
+	isMatch, runeCount, params := mux.patternMatch(pattern, host, path[:m-1])
+	return isMatch, runeCount, params, n + 1
+}
+
+// candidate is a route which allows a request, along with the measures match
+// uses to rank it against other candidate routes.
+type candidate struct {
+	route     *Route
+	params    url.Values    // params captured by the pattern
+	request   *http.Request // request derived by the route's rules
+	runeCount int           // num runes matched by the pattern
+	length    int           // length of the pattern
+	optional  bool          // true if the pattern has optional groups
+	quality   float64       // negotiated quality of the route
+	twin      bool          // true if the trailing slash twin of the pattern matched
+	extension string        // format extension stripped from the path to match
+}
+
+// beats returns true if the candidate should be preferred over the best
+// candidate so far.
+func (c *candidate) beats(best *candidate) bool {
+	wins, _ := c.compare(best)
+	return wins
+}
+
+// compare returns whether the candidate should be preferred over the best
+// candidate so far, and the measure which decided it.
+func (c *candidate) compare(best *candidate) (bool, string) {
+	switch {
+	case best.route == nil:
+		return true, "only candidate"
+	// prefer patterns matching more runes
+	case c.runeCount != best.runeCount:
+		return c.runeCount > best.runeCount, "runes matched"
+	// prefer explicit patterns over implicit trailing slash twins
+	case c.twin:
+		return false, "trailing slash twin"
+	case best.twin:
+		return c.length >= best.length, "pattern length"
+	// prefer patterns without optional groups
+	case c.optional != best.optional:
+		return !c.optional, "optional groups"
+	// prefer longer patterns, longer patterns excluding param names
+	case c.length != best.length:
+		return c.length > best.length, "pattern length"
+	case c.route.priority != best.route.priority:
+		return c.route.priority > best.route.priority, "priority"
+	case c.quality != best.quality:
+		return c.quality > best.quality, "negotiated quality"
+	case len(c.route.rules) != len(best.route.rules):
+		return len(c.route.rules) > len(best.route.rules), "number of rules"
+	default:
+		return false, "order considered"
 	}
-	return handler, reportPattern, params
+}
+
+// patternMatch returns whether the host and path match the given pattern, how
+// many runes matched, and the map of parameters captured from the host and
+// path. Host-specific patterns only match a non-empty host and generic
+// patterns only match an empty host. If the mux is CaseInsensitive, path
+// literals match case-insensitively.
+func (mux *ServeMux) patternMatch(pattern, host, path string) (bool, int, url.Values) {
+	hostPattern, pathPattern := splitPattern(pattern)
+	if (hostPattern == "") != (host == "") || pathPattern == "" {
+		return false, 0, nil
+	}
+	isMatch, runeCount, params, _ := optionalPathMatch(pathPattern, mux.expansions[pathPattern], path, mux.CaseInsensitive)
+	if !isMatch || hostPattern == "" {
+		return isMatch, runeCount, params
+	}
+	isMatch, hostRuneCount, hostParams := hostMatch(hostPattern, host)
+	if !isMatch {
+		return false, 0, nil
+	}
+	if len(hostParams) > 0 {
+		if params == nil {
+			params = make(url.Values)
+		}
+		// host params precede path params, in pattern order
+		for name, values := range hostParams {
+			params[name] = append(values, params[name]...)
+		}
+	}
+	return true, hostRuneCount + runeCount, params
 }
 
 // pathMatch returns whether the path matches the given pattern, how many
@@ -283,6 +686,13 @@ func (mux *ServeMux) match(request *http.Request, path string) (handler http.Han
 // patterns require the path to match exactly, while /tree/ patterns only
 // require the path to start with /tree/ (so pattern / matches all paths).
 func pathMatch(pattern, path string) (bool, int, url.Values) {
+	return foldPathMatch(pattern, path, false)
+}
+
+// foldPathMatch is like pathMatch, but if fold is true, pattern literals
+// match path runes case-insensitively, under Unicode simple case folding.
+// Captured param values are never folded.
+func foldPathMatch(pattern, path string, fold bool) (bool, int, url.Values) {
 	var params = make(url.Values)
 	var runeCount = 0
 
@@ -292,7 +702,7 @@ func pathMatch(pattern, path string) (bool, int, url.Values) {
 	}
 
 	// if pattern equals path, the path matches and the pattern has no capture params
-	if pattern == path {
+	if pattern == path && !strings.Contains(pattern, `\*`) {
 		return true, len([]rune(pattern)), nil
 	}
 
@@ -312,7 +722,24 @@ func pathMatch(pattern, path string) (bool, int, url.Values) {
 			name, i, next = captureName(rPattern, i+1) // param name after ':'
 			value, j = captureValue(rPath, j, next)
 			params.Add(":"+name, value)
-		case rPattern[i] == rPath[j]:
+		case isEscapedStar(rPattern, i):
+			// escaped '*' matches a literal '*'
+			if rPath[j] != '*' {
+				return false, runeCount, nil
+			}
+			i += 2
+			j++
+			runeCount++
+		case splatName(rPattern, i) != "":
+			// splat param captures each remaining path segment
+			name := splatName(rPattern, i)
+			segments := captureSegments(rPath, j)
+			if segments == nil {
+				return false, runeCount, nil
+			}
+			params[":"+name] = append(params[":"+name], segments...)
+			return true, runeCount, params
+		case rPattern[i] == rPath[j] || fold && equalFold(rPattern[i], rPath[j]):
 			i++
 			j++
 			runeCount++
@@ -333,6 +760,49 @@ func pathMatch(pattern, path string) (bool, int, url.Values) {
 	return true, runeCount, params
 }
 
+// equalFold returns true if the runes are equal under Unicode simple case
+// folding, as in strings.EqualFold.
+func equalFold(r1, r2 rune) bool {
+	if r1 == r2 {
+		return true
+	}
+	for r := unicode.SimpleFold(r1); r != r1; r = unicode.SimpleFold(r) {
+		if r == r2 {
+			return true
+		}
+	}
+	return false
+}
+
+// canonicalPath returns the path with the runes which matched the pattern's
+// literals case-insensitively replaced by the pattern's own runes. Captured
+// param values and any path runes past the pattern are kept as is.
+func canonicalPath(pattern, path string) string {
+	rPattern := []rune(pattern)
+	rPath := []rune(path)
+	var i, j int
+	for i < len(rPattern) && j < len(rPath) {
+		if rPattern[i] == ':' {
+			var next rune
+			_, i, next = captureName(rPattern, i+1)
+			_, j = captureValue(rPath, j, next)
+			continue
+		}
+		if isEscapedStar(rPattern, i) {
+			i += 2
+			j++
+			continue
+		}
+		if splatName(rPattern, i) != "" {
+			break
+		}
+		rPath[j] = rPattern[i]
+		i++
+		j++
+	}
+	return string(rPath)
+}
+
 // captureName captures the param name starting at the given rune index from
 // the pattern. Returns the captured name, the next rune index, and the next
 // non-variable rune or the zero value rune if no runes remain.
@@ -387,6 +857,14 @@ func isParamRune(r rune) bool {
 	}
 }
 
+// toggleSlash adds a trailing slash to the path or removes it, if present.
+func toggleSlash(path string) string {
+	if n := len(path); n > 0 && path[n-1] == '/' {
+		return path[:n-1]
+	}
+	return path + "/"
+}
+
 // cleanPath returns the canonical path, eliminating . and .. elements.
 func cleanPath(p string) string {
 	if p == "" {
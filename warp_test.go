@@ -83,30 +83,28 @@ func TestServeHTTP(t *testing.T) {
 	}
 }
 
-// test no duplicate implicit routes
+// test no implicit routes
 
 var implicitRedirectPatterns = []string{"/tree"}
 
 // Tests that explicitly registering a /tree/ multiple times does not cause
-// ServeMux to add duplicate useless /tree -> /tree/ implicit redirects.
+// ServeMux to add useless /tree -> /tree/ routes. Implicit redirects are
+// matched as trailing slash twins of the /tree/ pattern instead.
 func TestNoDuplicateImplicitRedirects(t *testing.T) {
 	mux := NewServeMux()
 	for _, route := range registerRoutes {
 		mux.Register(route.pattern, stringHandler(route.message), route.rules...)
 	}
 
-	var count int
 	for _, pattern := range implicitRedirectPatterns {
-		count = 0
-		for _, route := range mux.routes[pattern] {
-			if route.implicit {
-				count++
-			}
-		}
-		if count > 1 {
-			t.Errorf("pattern %s has %d implicit redirects, want 1", pattern, count)
+		if count := len(mux.routes[pattern]); count > 0 {
+			t.Errorf("pattern %s has %d implicit routes, want 0", pattern, count)
 		}
 	}
+	r := newRequest("GET", "/tree")
+	if _, pattern := mux.Handler(r); pattern != "/tree/" {
+		t.Errorf("GET /tree -> pattern %s, want /tree/", pattern)
+	}
 }
 
 // test method rules
@@ -165,13 +163,15 @@ var methodTests = []struct {
 	{"POST", "/tree/", 404, ""},
 	{"PUT", "/tree/", 404, ""},
 	{"DELETE", "/tree/", 200, "/tree/"},
-	// explicit routes override implicit redirects, wrong method falls back to redirect
+	// explicit routes override implicit redirects, wrong method falls back to
+	// redirect, which preserves the method
 	{"POST", "/tree", 200, "/tree"},
-	{"DELETE", "/tree", 301, "/tree/"},
+	{"DELETE", "/tree", 308, "/tree/"},
 	// tree pattern implicit redirects do not enforce tree method rules
-	{"POST", "/tree2", 301, "/tree2/"},
-	{"PUT", "/tree2", 301, "/tree2/"},
-	{"DELETE", "/tree2", 301, "/tree2/"},
+	{"GET", "/tree2", 301, "/tree2/"},
+	{"POST", "/tree2", 308, "/tree2/"},
+	{"PUT", "/tree2", 308, "/tree2/"},
+	{"DELETE", "/tree2", 308, "/tree2/"},
 }
 
 func TestHandlerMethods(t *testing.T) {
@@ -260,6 +260,10 @@ var pathMatchTests = []struct {
 	{"/foo/:file.:ext", "/foo/cats.png", true, 6, url.Values{":file": {"cats"}, ":ext": {"png"}}},
 	{"/foo/:file.:ext", "/foo/.png", true, 6, url.Values{":file": {""}, ":ext": {"png"}}},
 	{"/foo/:name.txt", "/foo/tim.txt", true, 9, url.Values{":name": {"tim"}}},
+	// captures end at the first occurrence of the following literal rune
+	{"/foo/:file.:ext", "/foo/archive.tar.gz", true, 6, url.Values{":file": {"archive"}, ":ext": {"tar.gz"}}},
+	{"/foo/:file.:ext", "/foo/archive", false, 5, nil},
+	{"/foo/:a-:b-:c", "/foo/1-2-3-4", true, 7, url.Values{":a": {"1"}, ":b": {"2"}, ":c": {"3-4"}}},
 
 	// pattern with capture param and literal at the same / level
 	{"/foo/x:name", "/foo/tim", false, 5, nil},
@@ -350,6 +354,170 @@ func TestServeHTTPParams(t *testing.T) {
 	}
 }
 
+// test trailing slash policies
+
+func registerSlashRoutes(mux *ServeMux) {
+	mux.Get("/notes", stringHandler("notes"))
+	mux.Get("/docs/", stringHandler("docs"))
+	mux.Get("/users/:id", stringHandler("user"))
+	mux.Get("/teams/:id/", stringHandler("team"))
+	mux.Handle("/", stringHandler("root"))
+}
+
+var slashTests = []struct {
+	policy   TrailingSlash
+	method   string // test request method
+	url      string // test request url
+	code     int    // expected HTTP response code
+	location string // expected redirect location
+	result   string // expected handler result
+}{
+	{RedirectToSlash, "GET", "/notes/", 200, "", "root"},
+	{RedirectToSlash, "GET", "/docs", 301, "/docs/", ""},
+	{RedirectToSlash, "GET", "/users/7/", 200, "", "root"},
+	{RedirectToSlash, "GET", "/teams/7", 301, "/teams/7/", ""},
+	// redirects do not enforce route rules, non-GET redirects preserve the method
+	{RedirectToSlash, "POST", "/teams/7", 308, "/teams/7/", ""},
+	// redirects keep the query string and are relative to the request host
+	{RedirectToSlash, "GET", "/docs?page=2&sort=asc", 301, "/docs/?page=2&sort=asc", ""},
+	{RedirectToSlash, "GET", "https://example.com/docs?page=2", 301, "/docs/?page=2", ""},
+
+	{StrictSlash, "GET", "/notes/", 200, "", "root"},
+	{StrictSlash, "GET", "/docs", 200, "", "root"},
+	{StrictSlash, "GET", "/teams/7", 200, "", "root"},
+	{StrictSlash, "GET", "/teams/7/", 200, "", "team"},
+
+	{RedirectToNoSlash, "GET", "/notes/", 301, "/notes", ""},
+	{RedirectToNoSlash, "GET", "/users/7/", 301, "/users/7", ""},
+	{RedirectToNoSlash, "GET", "/users/7/?tab=a%20b", 301, "/users/7?tab=a%20b", ""},
+	{RedirectToNoSlash, "GET", "/users/7/x/", 200, "", "root"},
+	{RedirectToNoSlash, "GET", "/docs", 200, "", "root"},
+	{RedirectToNoSlash, "GET", "/notes", 200, "", "notes"},
+
+	{IgnoreSlash, "GET", "/notes/", 200, "", "notes"},
+	{IgnoreSlash, "GET", "/notes", 200, "", "notes"},
+	{IgnoreSlash, "GET", "/docs", 200, "", "docs"},
+	{IgnoreSlash, "GET", "/users/7/", 200, "", "user"},
+	{IgnoreSlash, "GET", "/teams/7", 200, "", "team"},
+	// route rules are enforced when ignoring trailing slashes
+	{IgnoreSlash, "POST", "/teams/7", 200, "", "root"},
+}
+
+var redirectCodeTests = []struct {
+	redirectCode       int
+	methodRedirectCode int
+	method             string // test request method
+	url                string // test request url
+	code               int    // expected HTTP response code
+}{
+	{0, 0, "GET", "/docs", 301},
+	{0, 0, "HEAD", "/docs/../docs/", 301},
+	{0, 0, "POST", "/docs", 308},
+	{0, 0, "PUT", "/docs/./", 308},
+	{302, 307, "GET", "/docs", 302},
+	{302, 307, "POST", "/docs", 307},
+	{302, 307, "POST", "/docs/../docs/", 307},
+}
+
+func TestServeHTTPRedirectCodes(t *testing.T) {
+	for _, rt := range redirectCodeTests {
+		mux := NewServeMux()
+		mux.RedirectCode = rt.redirectCode
+		mux.MethodRedirectCode = rt.methodRedirectCode
+		mux.Handle("/docs/", stringHandler("docs"))
+
+		r := newRequest(rt.method, rt.url)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != rt.code {
+			t.Errorf("codes %d/%d %s %s -> code %d, want %d", rt.redirectCode, rt.methodRedirectCode, rt.method, rt.url, w.Code, rt.code)
+		}
+	}
+}
+
+func TestServeHTTPTrailingSlash(t *testing.T) {
+	for _, st := range slashTests {
+		mux := NewServeMux()
+		mux.TrailingSlash = st.policy
+		registerSlashRoutes(mux)
+
+		r := newRequest(st.method, st.url)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != st.code {
+			t.Errorf("policy %d %s %s -> code %d, want %d", st.policy, st.method, st.url, w.Code, st.code)
+		}
+		if location := w.Header().Get("Location"); location != st.location {
+			t.Errorf("policy %d %s %s -> location %q, want %q", st.policy, st.method, st.url, location, st.location)
+		}
+		if result := w.Header().Get("Result"); result != st.result {
+			t.Errorf("policy %d %s %s -> result %q, want %q", st.policy, st.method, st.url, result, st.result)
+		}
+	}
+}
+
+// test case-insensitive path matching
+
+func registerCaseRoutes(mux *ServeMux) {
+	mux.Get("/notes/:id", stringHandler("note"))
+	mux.Get("/Docs/", stringHandler("docs"))
+	mux.Get("/你好/:名", stringHandler("你好"))
+	mux.Get("/straße/:name", stringHandler("straße"))
+}
+
+var caseTests = []struct {
+	canonical bool   // whether to redirect to canonical casing
+	url       string // test request url
+	code      int    // expected HTTP response code
+	location  string // expected redirect location
+	params    url.Values
+}{
+	{false, "/notes/42", 200, "", url.Values{":id": {"42"}}},
+	{false, "/Notes/42", 200, "", url.Values{":id": {"42"}}},
+	// param values are not folded
+	{false, "/NOTES/AbC", 200, "", url.Values{":id": {"AbC"}}},
+	{false, "/docs/intro", 200, "", emptyParams},
+	{false, "/你好/Tim", 200, "", url.Values{":名": {"Tim"}}},
+	{false, "/STRASSE/x", 404, "", emptyParams},
+	{false, "/STRAẞE/x", 200, "", url.Values{":name": {"x"}}},
+	{true, "/notes/42", 200, "", url.Values{":id": {"42"}}},
+	{true, "/NOTES/AbC?v=1", 301, "/notes/AbC?v=1", url.Values{":id": {"AbC"}, "v": {"1"}}},
+	{true, "/DOCS/intro", 301, "/Docs/intro", emptyParams},
+	{true, "/Docs/intro", 200, "", emptyParams},
+	// canonical trailing slash redirect
+	{true, "/docs", 301, "/Docs/", emptyParams},
+}
+
+func TestServeHTTPCaseInsensitive(t *testing.T) {
+	for _, ct := range caseTests {
+		mux := NewServeMux()
+		mux.CaseInsensitive = true
+		mux.CanonicalRedirect = ct.canonical
+		registerCaseRoutes(mux)
+
+		r := newRequest("GET", ct.url)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != ct.code {
+			t.Errorf("canonical %t GET %s -> code %d, want %d", ct.canonical, ct.url, w.Code, ct.code)
+		}
+		if location := w.Header().Get("Location"); location != ct.location {
+			t.Errorf("canonical %t GET %s -> location %q, want %q", ct.canonical, ct.url, location, ct.location)
+		}
+		if !reflect.DeepEqual(r.URL.Query(), ct.params) {
+			t.Errorf("canonical %t GET %s -> params %v, want %v", ct.canonical, ct.url, r.URL.Query(), ct.params)
+		}
+	}
+}
+
+func TestCaseSensitiveByDefault(t *testing.T) {
+	mux := NewServeMux()
+	registerCaseRoutes(mux)
+	if _, pattern := mux.Handler(newRequest("GET", "/Notes/42")); pattern != "" {
+		t.Errorf("GET /Notes/42 -> pattern %s, want no match", pattern)
+	}
+}
+
 // test route priorities - higher priority routes have patterns that are
 // host-specific, match more non-capture runes (longer minus param names),
 // and are explicit rather than implicit.
@@ -367,6 +535,13 @@ var priorityRoutes = []struct {
 	{"/notes/:identifier", nil},
 	{"/pages/", nil},
 	{"/pages/:number", nil},
+	{"/reports/:year(/:month)", nil},
+	{"/reports/:year/summary", nil},
+	{"/archive/:year(/:month(/:day))", nil},
+	{"/archive/:year/:month/:day", nil},
+	{"/files/:name", nil},
+	{"/files/:name.:ext", nil},
+	{"/files/:name.json", nil},
 }
 
 var routePriorityTests = []struct {
@@ -388,6 +563,20 @@ var routePriorityTests = []struct {
 	{"GET", "/pages/", "/pages/"},
 	{"GET", "/pages/61", "/pages/:number"},
 	{"GET", "/pages/66", "/pages/:number"},
+	// optional groups match with or without the group
+	{"GET", "/reports/2024", "/reports/:year(/:month)"},
+	{"GET", "/reports/2024/05", "/reports/:year(/:month)"},
+	// literal runes outweigh optional captures
+	{"GET", "/reports/2024/summary", "/reports/:year/summary"},
+	// nested optional groups
+	{"GET", "/archive/2024", "/archive/:year(/:month(/:day))"},
+	{"GET", "/archive/2024/05", "/archive/:year(/:month(/:day))"},
+	// patterns without optional groups are preferred on otherwise equal matches
+	{"GET", "/archive/2024/05/17", "/archive/:year/:month/:day"},
+	// multiple captures per segment, more literal runes are preferred
+	{"GET", "/files/notes", "/files/:name"},
+	{"GET", "/files/notes.txt", "/files/:name.:ext"},
+	{"GET", "/files/notes.json", "/files/:name.json"},
 	// if routes are ambiguous (e.g. /:a/:b and /:b/:a), the path matches one
 	// at random, no guarantees provided
 }
@@ -419,3 +608,39 @@ type ServeMuxer interface {
 // compile-time assertions
 var _ ServeMuxer = http.NewServeMux()
 var _ ServeMuxer = NewServeMux()
+
+func TestUse(t *testing.T) {
+	mux := NewServeMux()
+	var calls []string
+	trace := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				pattern := ""
+				if route := RequestRoute(r); route != nil {
+					pattern = route.Pattern()
+				}
+				calls = append(calls, name+" "+pattern)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	mux.Use(trace("outer"), trace("inner"))
+	mux.Get("/notes/:id", stringHandler("note"))
+
+	cases := []struct {
+		url   string
+		code  int
+		calls []string
+	}{
+		{"/notes/1", 200, []string{"outer /notes/:id", "inner /notes/:id"}},
+		{"/missing", 404, []string{"outer ", "inner "}},
+	}
+	for _, c := range cases {
+		calls = nil
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, newRequest("GET", c.url))
+		if w.Code != c.code || !reflect.DeepEqual(calls, c.calls) {
+			t.Errorf("GET %s -> %d %v, want %d %v", c.url, w.Code, calls, c.code, c.calls)
+		}
+	}
+}